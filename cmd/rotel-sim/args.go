@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	emulator "github.com/djthorpe/go-rotel/pkg/rotel/emulator"
+	version "github.com/djthorpe/go-rotel/pkg/version"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Args struct {
+	*flag.FlagSet
+
+	// Flags
+	Model       string
+	State       string
+	Latency     time.Duration
+	Listen      string
+	Interactive bool
+	Version     bool
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	ErrHelp = flag.ErrHelp
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewArgs(name string, args []string) (*Args, error) {
+	self := &Args{
+		FlagSet: flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+
+	// Register flags
+	self.registerFlags()
+
+	// Parse flags
+	if err := self.Parse(args); err != nil {
+		return nil, err
+	}
+	// No arguments are allowed
+	if self.NArg() > 0 {
+		return nil, ErrBadParameter.Withf("unexpected argument: %q", self.Arg(0))
+	}
+	// Print version and exit
+	if self.Version {
+		version.Print(os.Stdout)
+		return nil, ErrHelp
+	}
+
+	return self, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (self *Args) String() string {
+	str := "<flags"
+	if self.Name() != "" {
+		str += fmt.Sprintf(" name=%q", self.Name())
+	}
+	str += fmt.Sprintf(" model=%q", self.Model)
+	if self.State != "" {
+		str += fmt.Sprintf(" state=%q", self.State)
+	}
+	if self.Latency != 0 {
+		str += fmt.Sprintf(" latency=%v", self.Latency)
+	}
+	if self.Listen != "" {
+		str += fmt.Sprintf(" listen=%q", self.Listen)
+	}
+	str += fmt.Sprintf(" interactive=%v", self.Interactive)
+	return str + ">"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (self *Args) registerFlags() {
+	self.StringVar(&self.Model, "model", emulator.DEFAULT_MODEL, "Model to emulate: "+strings.Join(rotel.Models(), ", "))
+	self.StringVar(&self.State, "state", "", "Initial state as JSON, or a JSON file (optional)")
+	self.DurationVar(&self.Latency, "latency", 0, "Delay before each response")
+	self.StringVar(&self.Listen, "listen", "", "Address to listen on for TCP connections, instead of a pseudo-terminal (optional)")
+	self.BoolVar(&self.Interactive, "interactive", false, "Read front panel commands from standard input")
+	self.BoolVar(&self.Version, "version", false, "Print version and exit")
+}
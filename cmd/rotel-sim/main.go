@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+func main() {
+	name := filepath.Base(os.Args[0])
+	flags, err := NewArgs(name, os.Args[1:])
+	if err == ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	// Create a context which cancels on CTRL+C
+	ctx := HandleSignal()
+	sim, err := NewSim(flags.Model, flags.State, flags.Latency)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	fmt.Println(flags)
+
+	// Read front panel commands
+	if flags.Interactive {
+		go sim.Interactive(ctx, os.Stdin, os.Stdout)
+	}
+
+	// Run the simulator on a pseudo-terminal or TCP
+	if flags.Listen != "" {
+		err = sim.RunTCP(ctx, flags.Listen)
+	} else {
+		err = sim.RunPty(ctx)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+}
+
+// Handle signals - call cancel when interrupt received
+func HandleSignal() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-ch
+		cancel()
+	}()
+	return ctx
+}
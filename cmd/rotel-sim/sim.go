@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	emulator "github.com/djthorpe/go-rotel/pkg/rotel/emulator"
+	term "github.com/pkg/term"
+	termios "github.com/pkg/term/termios"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Sim struct {
+	mu      sync.Mutex
+	model   string
+	latency time.Duration
+	state   rotel.State        // State when there is no connection
+	current *emulator.Emulator // Emulator for the current connection
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// NewSim returns a simulator for a model. The initial state is JSON, or
+// the path to a JSON file, or empty for the default state
+func NewSim(model, state string, latency time.Duration) (*Sim, error) {
+	self := new(Sim)
+
+	// Check the model is known
+	models := rotel.Models()
+	for _, name := range models {
+		if rotel.ProfileForModel(model).Name == name {
+			self.model = model
+			break
+		}
+	}
+	if self.model == "" {
+		return nil, ErrBadParameter.Withf("model: %q (expected one of %s)", model, strings.Join(models, ", "))
+	}
+	self.latency = latency
+	self.state = emulator.DefaultState()
+
+	// Read the initial state
+	if state = strings.TrimSpace(state); state != "" {
+		data := []byte(state)
+		if !strings.HasPrefix(state, "{") {
+			if data_, err := os.ReadFile(state); err != nil {
+				return nil, err
+			} else {
+				data = data_
+			}
+		}
+		if err := json.Unmarshal(data, &self.state); err != nil {
+			return nil, ErrBadParameter.Withf("state: %v", err)
+		}
+	}
+
+	// Return success
+	return self, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// RunPty runs the simulator on a pseudo-terminal until the context is
+// cancelled
+func (self *Sim) RunPty(ctx context.Context) error {
+	ptm, pts, err := termios.Pty()
+	if err != nil {
+		return err
+	}
+	defer pts.Close()
+
+	// Keep the terminal open in raw mode, so that it remains open when the
+	// client disconnects
+	tty, err := term.Open(pts.Name(), term.RawMode)
+	if err != nil {
+		ptm.Close()
+		return err
+	}
+	defer tty.Close()
+
+	fmt.Printf("emulating %s on %s\n", self.model, pts.Name())
+	return self.run(ctx, ptm)
+}
+
+// RunTCP runs the simulator for each TCP connection in turn until the
+// context is cancelled
+func (self *Sim) RunTCP(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	fmt.Printf("emulating %s on %s\n", self.model, listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Println("connection from", conn.RemoteAddr())
+		if err := self.run(ctx, conn); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// Interactive reads front panel commands, for example vol_up or
+// power_off, and prints the state with the command "state"
+func (self *Sim) Interactive(ctx context.Context, r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() && ctx.Err() == nil {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "state":
+			data, _ := json.MarshalIndent(self.State(), "", "  ")
+			fmt.Fprintln(w, string(data))
+		default:
+			if err := self.FrontPanel(line); err != nil {
+				fmt.Fprintln(w, "error:", err)
+			}
+		}
+	}
+}
+
+// State returns the state of the simulator
+func (self *Sim) State() rotel.State {
+	self.mu.Lock()
+	e, state := self.current, self.state
+	self.mu.Unlock()
+	if e != nil {
+		return e.State()
+	}
+	return state
+}
+
+// FrontPanel applies a command as if from the front panel. The lock is
+// not held while the emulator waits for the latency and writes the update
+func (self *Sim) FrontPanel(cmd string) error {
+	self.mu.Lock()
+	e := self.current
+	self.mu.Unlock()
+	if e == nil {
+		return ErrOutOfOrder.With("no connection")
+	}
+	return e.FrontPanel(cmd)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// run runs an emulator on a connection, keeping the state afterwards for
+// the next connection
+func (self *Sim) run(ctx context.Context, conn io.ReadWriteCloser) error {
+	self.mu.Lock()
+	self.current = emulator.New(conn, emulator.Config{
+		Model:   self.model,
+		State:   self.state,
+		Latency: self.latency,
+	})
+	e := self.current
+	self.mu.Unlock()
+
+	// Run until the connection is closed
+	err := e.Run(ctx)
+
+	self.mu.Lock()
+	self.state = e.State()
+	self.current = nil
+	self.mu.Unlock()
+
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// Package imports
+	emulator "github.com/djthorpe/go-rotel/pkg/rotel/emulator"
+
+	// Namespace imports
+	goerrors "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Sim_001(t *testing.T) {
+	// Known models are accepted in any form, and unknown models rejected
+	for _, model := range []string{"A14", "a12", "RSP-1576", "Michi P5", "x3"} {
+		if _, err := NewSim(model, "", 0); err != nil {
+			t.Error(model, err)
+		}
+	}
+	for _, model := range []string{"", "A99", "RSP-9999", "michi"} {
+		if _, err := NewSim(model, "", 0); !errors.Is(err, goerrors.ErrBadParameter) {
+			t.Errorf("%q: unexpected error: %v", model, err)
+		}
+	}
+}
+
+func Test_Sim_002(t *testing.T) {
+	// The initial state is JSON, or a JSON file, or the default state
+	file := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(file, []byte(`{"power":true,"volume":42,"source":"opt1"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if sim, err := NewSim("A14", "", 0); err != nil {
+		t.Error(err)
+	} else if state := sim.State(); state != emulator.DefaultState() {
+		t.Error("unexpected state", state)
+	}
+	for _, state := range []string{` {"power":true,"volume":42,"source":"opt1"} `, file} {
+		if sim, err := NewSim("A14", state, 0); err != nil {
+			t.Error(state, err)
+		} else if s := sim.State(); !s.Power || s.Volume != 42 || s.Source != "opt1" || !s.SpeakerA {
+			t.Error(state, "unexpected state", s)
+		}
+	}
+
+	// Invalid JSON and missing files are errors
+	if _, err := NewSim("A14", `{"power":`, 0); !errors.Is(err, goerrors.ErrBadParameter) {
+		t.Error("unexpected error:", err)
+	}
+	if _, err := NewSim("A14", filepath.Join(t.TempDir(), "missing.json"), 0); !os.IsNotExist(err) {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func Test_Sim_003(t *testing.T) {
+	// The state is read while a front panel update waits for the latency
+	const latency = 500 * time.Millisecond
+	sim, err := NewSim("A14", `{"power":true,"volume":30,"source":"cd"}`, latency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, conn := net.Pipe()
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sim.run(ctx, conn)
+
+	// Enable updates, and read responses until the connection is closed
+	if _, err := client.Write([]byte("rs232_update_on!")); err != nil {
+		t.Fatal(err)
+	}
+	go io.Copy(io.Discard, client)
+	time.Sleep(2 * latency)
+
+	// Change the volume, which writes an update after the latency
+	done := make(chan error)
+	go func() {
+		done <- sim.FrontPanel("vol_up")
+	}()
+	time.Sleep(latency / 5)
+	start := time.Now()
+	if volume := sim.State().Volume; volume != 31 {
+		t.Error("unexpected volume", volume)
+	}
+	if elapsed := time.Since(start); elapsed > latency/2 {
+		t.Error("state was blocked for", elapsed)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
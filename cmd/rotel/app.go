@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	// Package imports
 	ha "github.com/djthorpe/go-rotel/pkg/ha"
@@ -28,7 +30,9 @@ type App struct {
 
 	client *mosquitto.Client // MQTT
 	rotel  *rotel.Rotel
-	ha     *ha.HA // Home assistant
+	cd     *rotel.CDPlayer // Optional CD player
+	tuner  *rotel.Tuner    // Optional tuner
+	ha     *ha.HA          // Home assistant
 	qos    int
 	topic  string
 	id     string
@@ -36,11 +40,37 @@ type App struct {
 	// Event channel
 	evtch chan *mosquitto.Event
 
-	// State change channel
-	statech chan StateChange
+	// State changes from home assistant and devices, which are queued and
+	// then published and applied by the run loop
+	mu      sync.Mutex
+	changes []StateChange
+	statech chan struct{} // Signalled when changes are queued
 
 	// Online/Offline messages
 	topicStatusId string
+
+	// State history, published on request
+	topicHistory string
+
+	// Surround processor components, added once the model is known
+	processor *Processor
+
+	// Phono mode, added once the model is known
+	phono ha.Component
+
+	// CD player components
+	cdplayer *CDPlayer
+
+	// Tuner components
+	radio *Radio
+}
+
+type Processor struct {
+	Mode      ha.Component
+	Format    ha.Component
+	Center    ha.Component
+	Subwoofer ha.Component
+	Surround  ha.Component
 }
 
 type StateChange struct {
@@ -51,11 +81,14 @@ type StateChange struct {
 ///////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
-func NewApp(ctx context.Context, prefix, broker, credentials, id string, qos int, topic string, tty string) (*App, error) {
+// NewApp returns an app which connects to the broker and to the amplifier
+// with the configuration cfg. The CD player and tuner are optional, and
+// use the serial line settings of the amplifier on their own ports
+func NewApp(ctx context.Context, prefix, broker, credentials, id string, qos int, topic, history, cdtty, tunertty string, cfg rotel.Config) (*App, error) {
 	self := new(App)
 
 	// Broker configuration
-	cfg := mosquitto.NewConfigWithBroker(broker).WithCallback(func(evt *mosquitto.Event) {
+	mqtt := mosquitto.NewConfigWithBroker(broker).WithCallback(func(evt *mosquitto.Event) {
 		if evt.Type == MOSQ_FLAG_EVENT_MESSAGE {
 			if self.evtch != nil {
 				self.evtch <- evt
@@ -64,27 +97,49 @@ func NewApp(ctx context.Context, prefix, broker, credentials, id string, qos int
 	})
 	if credentials := strings.TrimSpace(credentials); credentials != "" {
 		userpass := strings.SplitN(credentials, ":", 2)
-		cfg = cfg.WithCredentials(userpass[0], userpass[1])
+		mqtt = mqtt.WithCredentials(userpass[0], userpass[1])
 	}
 
 	// Connect to broker
-	client, err := mosquitto.NewWithConfig(ctx, cfg)
+	client, err := mosquitto.NewWithConfig(ctx, mqtt)
 	if err != nil {
 		return nil, fmt.Errorf("MQTT: %q: %w", broker, err)
+	} else {
+		self.client = client
 	}
 
 	// Home assistant
 	ha, err := ha.New(topic, self.StateCallback)
 	if err != nil {
+		self.close()
 		return nil, fmt.Errorf("Home Assistant: %q: %w", topic, err)
 	}
 
+	// Rotel CD player on a second serial port
+	if cdtty != "" {
+		cd, err := rotel.NewCDPlayerWithConfig(serial(cfg, cdtty))
+		if err != nil {
+			self.close()
+			return nil, fmt.Errorf("Rotel CD player: %q: %w", cdtty, err)
+		}
+		self.cd = cd
+	}
+
+	// Rotel tuner on another serial port
+	if tunertty != "" {
+		tuner, err := rotel.NewTunerWithConfig(serial(cfg, tunertty))
+		if err != nil {
+			self.close()
+			return nil, fmt.Errorf("Rotel tuner: %q: %w", tunertty, err)
+		}
+		self.tuner = tuner
+	}
+
 	// Rotel amplifier
-	rotel, err := rotel.NewWithConfig(rotel.Config{
-		TTY: tty,
-	})
+	rotel, err := rotel.NewWithConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("Rotel: %q: %w", tty, err)
+		self.close()
+		return nil, fmt.Errorf("Rotel: %q: %w", cfg.TTY, err)
 	}
 
 	// Initialise logger
@@ -92,9 +147,9 @@ func NewApp(ctx context.Context, prefix, broker, credentials, id string, qos int
 
 	// Set app parameters
 	self.qos = qos
-	self.client = client
 	self.ha = ha
 	self.rotel = rotel
+	self.topicHistory = strings.TrimSpace(history)
 	self.id = fmt.Sprintf("%s_%s", "rotel", strings.TrimSpace(id))
 
 	// Return success
@@ -107,28 +162,69 @@ func NewApp(ctx context.Context, prefix, broker, credentials, id string, qos int
 // runloop for the rotel app
 func (self *App) Run(ctx context.Context) error {
 	var wg sync.WaitGroup
-	var result error
+	var result, cdresult, tunerresult error
 
 	// Create channels for events and state changes
 	self.evtch = make(chan *mosquitto.Event, 1)
-	self.statech = make(chan StateChange, 2)
-	rotelch := make(chan rotel.Event, 1)
+	self.statech = make(chan struct{}, 1)
+	rotelch := self.rotel.Subscribe(ctx, rotel.ROTEL_FLAG_NONE)
+
+	// The CD player channel is nil when there is no CD player
+	var cdch <-chan rotel.Event
+	if self.cd != nil {
+		cdch = self.cd.Subscribe(ctx, rotel.ROTEL_FLAG_NONE)
+	}
+
+	// The tuner channel is nil when there is no tuner
+	var tunerch <-chan rotel.Event
+	if self.tuner != nil {
+		tunerch = self.tuner.Subscribe(ctx, rotel.ROTEL_FLAG_NONE)
+	}
 
 	// Run rotel amplifier in background
 	wg.Add(1)
 	go func(ctx context.Context) {
 		defer wg.Done()
-		if err := self.rotel.Run(ctx, rotelch); err != nil {
+		if err := self.rotel.Run(ctx, nil); err != nil {
 			result = errors.Join(result, err)
 		}
 	}(ctx)
 
+	// Run CD player in background
+	if self.cd != nil {
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			if err := self.cd.Run(ctx, nil); err != nil {
+				cdresult = err
+			}
+		}(ctx)
+	}
+
+	// Run tuner in background
+	if self.tuner != nil {
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			if err := self.tuner.Run(ctx, nil); err != nil {
+				tunerresult = err
+			}
+		}(ctx)
+	}
+
 	// Subscribe to the "status" topic to get online/offline messages
 	self.topicStatusId = self.ha.TopicStatus()
 	if _, err := self.client.Subscribe(self.topicStatusId, mosquitto.OptQoS(self.qos)); err != nil {
 		return err
 	}
 
+	// Subscribe to requests for state history
+	if self.topicHistory != "" {
+		if _, err := self.client.Subscribe(self.topicHistory+"/get", mosquitto.OptQoS(self.qos)); err != nil {
+			return err
+		}
+	}
+
 	// Add a power button
 	power, err := self.ha.AddPowerButton(self.id, "power")
 	if err != nil {
@@ -193,6 +289,27 @@ func (self *App) Run(ctx context.Context) error {
 		return err
 	}
 
+	// Amplifier components, which are published again when the model is known
+	components := []ha.Component{power, speakerA, speakerB, volume, bass, treble, source}
+
+	// Add CD player
+	if self.cd != nil {
+		if cdplayer, err := self.AddCDPlayer(); err != nil {
+			return err
+		} else {
+			self.cdplayer = cdplayer
+		}
+	}
+
+	// Add tuner
+	if self.tuner != nil {
+		if radio, err := self.AddRadio(); err != nil {
+			return err
+		} else {
+			self.radio = radio
+		}
+	}
+
 FOR_LOOP:
 	for {
 		select {
@@ -206,103 +323,215 @@ FOR_LOOP:
 					log.Println("Home assistant status has changed:", self.ha)
 					// TODO: Get all latest rotel state
 				}
+			} else if self.topicHistory != "" && evt.Topic == self.topicHistory+"/get" {
+				if err := self.PublishHistory(evt.Data); err != nil {
+					log.Println("error publishing history:", err)
+				}
 			} else if err := self.ha.Command(evt.Topic, evt.Data); err != nil {
 				log.Println("other event: ", evt)
 			}
-		case evt := <-self.statech:
-			self.Logger.Println("publishing", string(evt.Data), "to", evt.Component.StateTopic())
-			if _, err := self.client.Publish(evt.Component.StateTopic(), evt.Data); err != nil {
-				return err
-			}
-			if evt.Component == power {
-				if err := self.rotel.SetPower(string(evt.Data) == "ON"); err != nil {
-					log.Println("error setting power:", err)
+		case <-self.statech:
+			for _, evt := range self.takeChanges() {
+				if topic := evt.Component.StateTopic(); topic != "" {
+					self.Logger.Println("publishing", string(evt.Data), "to", topic)
+					if _, err := self.client.Publish(topic, evt.Data); err != nil {
+						return err
+					}
 				}
-			}
-			if evt.Component == speakerA {
-				if err := self.rotel.SetSpeaker(string(evt.Data) == "ON", "a"); err != nil {
-					log.Println("error setting speaker A:", err)
+				if self.cdplayer != nil {
+					self.CDPlayerCommand(evt.Component, evt.Data)
 				}
-			}
-			if evt.Component == speakerB {
-				if err := self.rotel.SetSpeaker(string(evt.Data) == "ON", "b"); err != nil {
-					log.Println("error setting speaker B:", err)
+				if self.radio != nil {
+					self.RadioCommand(evt.Component, evt.Data)
 				}
-			}
-			if evt.Component == volume {
-				if value, err := strconv.ParseUint(string(evt.Data), 10, 32); err != nil {
-					log.Println("error parsing volume:", err)
-				} else if err := self.rotel.SetVolume(uint(value)); err != nil {
-					log.Println("error setting volume:", err)
+				if evt.Component == power {
+					if err := self.rotel.SetPower(string(evt.Data) == "ON"); err != nil {
+						self.CommandError("power", err)
+					}
 				}
-			}
-			if evt.Component == source {
-				if err := self.rotel.SetSource(string(evt.Data)); err != nil {
-					log.Println("error setting source:", err)
+				if evt.Component == speakerA {
+					if err := self.rotel.SetSpeaker(string(evt.Data) == "ON", "a"); err != nil {
+						self.CommandError("speaker A", err)
+					}
 				}
-			}
-			if evt.Component == bass {
-				if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
-					log.Println("error parsing bass:", err)
-				} else if err := self.rotel.SetBass(int(value)); err != nil {
-					log.Println("error setting bass:", err)
+				if evt.Component == speakerB {
+					if err := self.rotel.SetSpeaker(string(evt.Data) == "ON", "b"); err != nil {
+						self.CommandError("speaker B", err)
+					}
 				}
-			}
-			if evt.Component == treble {
-				if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
-					log.Println("error parsing treble:", err)
-				} else if err := self.rotel.SetTreble(int(value)); err != nil {
-					log.Println("error setting treble:", err)
+				if evt.Component == volume {
+					if value, err := strconv.ParseUint(string(evt.Data), 10, 32); err != nil {
+						log.Println("error parsing volume:", err)
+					} else if err := self.rotel.SetVolume(uint(value)); err != nil {
+						self.CommandError("volume", err)
+					}
+				}
+				if evt.Component == source {
+					if err := self.rotel.SetSource(string(evt.Data)); err != nil {
+						self.CommandError("source", err)
+					}
+				}
+				if evt.Component == bass {
+					if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
+						log.Println("error parsing bass:", err)
+					} else if err := self.rotel.SetBass(int(value)); err != nil {
+						self.CommandError("bass", err)
+					}
+				}
+				if evt.Component == treble {
+					if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
+						log.Println("error parsing treble:", err)
+					} else if err := self.rotel.SetTreble(int(value)); err != nil {
+						self.CommandError("treble", err)
+					}
+				}
+				if self.phono != nil && evt.Component == self.phono {
+					if err := self.rotel.SetPhonoMode(string(evt.Data)); err != nil {
+						self.CommandError("phono mode", err)
+					}
+				}
+				if self.processor != nil {
+					if evt.Component == self.processor.Mode {
+						if err := self.rotel.SetMode(string(evt.Data)); err != nil {
+							self.CommandError("mode", err)
+						}
+					}
+					if evt.Component == self.processor.Center {
+						if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
+							log.Println("error parsing center:", err)
+						} else if err := self.rotel.SetCenter(int(value)); err != nil {
+							self.CommandError("center", err)
+						}
+					}
+					if evt.Component == self.processor.Subwoofer {
+						if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
+							log.Println("error parsing subwoofer:", err)
+						} else if err := self.rotel.SetSubwoofer(int(value)); err != nil {
+							self.CommandError("subwoofer", err)
+						}
+					}
+					if evt.Component == self.processor.Surround {
+						if value, err := strconv.ParseInt(string(evt.Data), 10, 32); err != nil {
+							log.Println("error parsing surround:", err)
+						} else if err := self.rotel.SetSurround(int(value)); err != nil {
+							self.CommandError("surround", err)
+						}
+					}
 				}
 			}
+		case evt := <-cdch:
+			self.CDPlayerEvent(evt)
+		case evt := <-tunerch:
+			self.RadioEvent(evt)
 		case evt := <-rotelch:
+			state, _ := evt.New.(rotel.State)
 			if evt.Err != nil {
-				self.Logger.Println("rotel error", evt.Err)
+				self.EventError("rotel", evt.Err)
+			}
+			if evt.Flag.Is(rotel.ROTEL_FLAG_OFFLINE) {
+				if state.Offline {
+					self.Logger.Println("rotel is not responding")
+				} else {
+					self.Logger.Println("rotel is responding")
+				}
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_MODEL) {
-				self.Logger.Println("rotel model=", self.rotel.Model())
+				profile := rotel.ProfileForModel(state.Model)
+				self.Logger.Println("rotel model=", profile.Name)
+				if profile.Processor && self.processor == nil {
+					if processor, err := self.AddProcessor(); err != nil {
+						return err
+					} else {
+						self.processor = processor
+						components = append(components, processor.Mode, processor.Format, processor.Center, processor.Subwoofer, processor.Surround)
+					}
+				}
+				if profile.Phono && self.phono == nil {
+					if phono, err := self.AddPhono(); err != nil {
+						return err
+					} else {
+						self.phono = phono
+						components = append(components, phono)
+					}
+				}
+
+				// Set volume range and sources for the model, and publish with the device
+				volume.(*ha.Volume).SetRange(float32(profile.VolumeMin), float32(profile.VolumeMax))
+				source.(*ha.Input).SetOptions(profile.Sources)
+				if err := self.PublishDevice(ha.NewDevice(self.id, "Rotel", profile.Name), components...); err != nil {
+					return err
+				}
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_POWER) {
-				if self.rotel.Power() {
+				if state.Power {
 					self.StateCallback(power, []byte("ON"))
 				} else {
 					self.StateCallback(power, []byte("OFF"))
 				}
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_SPEAKER) {
-				if self.rotel.SpeakerA() {
+				if state.SpeakerA {
 					self.StateCallback(speakerA, []byte("ON"))
 				} else {
 					self.StateCallback(speakerA, []byte("OFF"))
 				}
 
-				if self.rotel.SpeakerB() {
+				if state.SpeakerB {
 					self.StateCallback(speakerB, []byte("ON"))
 				} else {
 					self.StateCallback(speakerB, []byte("OFF"))
 				}
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_VOLUME) {
-				str := fmt.Sprintf("%d", self.rotel.Volume())
+				str := fmt.Sprintf("%d", state.Volume)
 				self.StateCallback(volume, []byte(str))
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_SOURCE) {
-				v := self.rotel.Source()
+				v := state.Source
 				self.StateCallback(source, []byte(v))
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_BASS) {
-				str := fmt.Sprintf("%d", self.rotel.Bass())
+				str := fmt.Sprintf("%d", state.Bass)
 				self.StateCallback(bass, []byte(str))
 			}
 			if evt.Flag.Is(rotel.ROTEL_FLAG_TREBLE) {
-				str := fmt.Sprintf("%d", self.rotel.Treble())
+				str := fmt.Sprintf("%d", state.Treble)
 				self.StateCallback(treble, []byte(str))
 			}
+			if self.phono != nil && evt.Flag.Is(rotel.ROTEL_FLAG_PHONO) {
+				self.StateCallback(self.phono, []byte(state.PhonoMode))
+			}
+			if self.processor != nil {
+				if evt.Flag.Is(rotel.ROTEL_FLAG_MODE) {
+					self.StateCallback(self.processor.Mode, []byte(state.Mode))
+				}
+				if evt.Flag.Is(rotel.ROTEL_FLAG_FORMAT) {
+					self.StateCallback(self.processor.Format, []byte(state.Format))
+				}
+				if evt.Flag.Is(rotel.ROTEL_FLAG_CENTER) {
+					str := fmt.Sprintf("%d", state.Center)
+					self.StateCallback(self.processor.Center, []byte(str))
+				}
+				if evt.Flag.Is(rotel.ROTEL_FLAG_SUBWOOFER) {
+					str := fmt.Sprintf("%d", state.Subwoofer)
+					self.StateCallback(self.processor.Subwoofer, []byte(str))
+				}
+				if evt.Flag.Is(rotel.ROTEL_FLAG_SURROUND) {
+					str := fmt.Sprintf("%d", state.Surround)
+					self.StateCallback(self.processor.Surround, []byte(str))
+				}
+			}
 		}
 	}
 
-	// Wait for rotel to finish
+	// Wait for rotel, CD player and tuner to finish
 	wg.Wait()
+	if cdresult != nil {
+		result = errors.Join(result, cdresult)
+	}
+	if tunerresult != nil {
+		result = errors.Join(result, tunerresult)
+	}
 
 	// Unpublish components
 	for _, component := range self.ha.Components() {
@@ -324,6 +553,159 @@ FOR_LOOP:
 	return result
 }
 
+// AddProcessor adds and publishes the surround processor components
+func (self *App) AddProcessor() (*Processor, error) {
+	var err error
+	processor := new(Processor)
+
+	// Add listening mode
+	if processor.Mode, err = self.ha.AddSelect(self.id, "mode", "Mode", rotel.MODES); err != nil {
+		return nil, err
+	}
+
+	// Add decoded format
+	if processor.Format, err = self.ha.AddSensor(self.id, "format", "Format"); err != nil {
+		return nil, err
+	}
+
+	// Add channel trims
+	if processor.Center, err = self.ha.AddSlider(self.id, "center", "Center"); err != nil {
+		return nil, err
+	}
+	processor.Center.(*ha.Slider).SetRange(rotel.TRIM_MIN, rotel.TRIM_MAX)
+	if processor.Subwoofer, err = self.ha.AddSlider(self.id, "subwoofer", "Subwoofer"); err != nil {
+		return nil, err
+	}
+	processor.Subwoofer.(*ha.Slider).SetRange(rotel.TRIM_MIN, rotel.TRIM_MAX)
+	if processor.Surround, err = self.ha.AddSlider(self.id, "surround", "Surround"); err != nil {
+		return nil, err
+	}
+	processor.Surround.(*ha.Slider).SetRange(rotel.TRIM_MIN, rotel.TRIM_MAX)
+
+	// Publish components
+	for _, component := range []ha.Component{processor.Mode, processor.Format, processor.Center, processor.Subwoofer, processor.Surround} {
+		if err := self.PublishComponent(component, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Return success
+	return processor, nil
+}
+
+// AddPhono adds and publishes the phono mode select
+func (self *App) AddPhono() (ha.Component, error) {
+	phono, err := self.ha.AddSelect(self.id, "phono_mode", "Phono Mode", rotel.PHONO_MODES)
+	if err != nil {
+		return nil, err
+	}
+	if err := self.PublishComponent(phono, true); err != nil {
+		return nil, err
+	}
+	return phono, nil
+}
+
+// takeChanges returns the queued state changes and clears the queue
+func (self *App) takeChanges() []StateChange {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	changes := self.changes
+	self.changes = nil
+	return changes
+}
+
+// close closes the broker connection and any devices already opened, when
+// the app cannot be created
+func (self *App) close() {
+	// Run the devices with a cancelled context, which closes the ports
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if self.cd != nil {
+		self.cd.Run(ctx, nil)
+	}
+	if self.tuner != nil {
+		self.tuner.Run(ctx, nil)
+	}
+	self.client.Close()
+}
+
+// serial returns the serial line settings of the amplifier configuration
+// for another device on the port tty
+func serial(cfg rotel.Config, tty string) rotel.Config {
+	return rotel.Config{
+		TTY:      tty,
+		Baud:     cfg.Baud,
+		Parity:   cfg.Parity,
+		DataBits: cfg.DataBits,
+		StopBits: cfg.StopBits,
+		Flow:     cfg.Flow,
+		DTR:      cfg.DTR,
+		RTS:      cfg.RTS,
+	}
+}
+
+// CommandError logs an error from sending a command to a device
+func (self *App) CommandError(name string, err error) {
+	switch {
+	case errors.Is(err, rotel.ErrPowerOff):
+		self.Logger.Printf("ignoring %s while power is off", name)
+	case errors.Is(err, ErrBadParameter), errors.Is(err, rotel.ErrUnsupported):
+		self.Logger.Printf("rejected %s: %v", name, err)
+	default:
+		self.Logger.Printf("error setting %s: %v", name, err)
+	}
+}
+
+// EventError logs an error reported by a device
+func (self *App) EventError(name string, err error) {
+	switch {
+	case errors.Is(err, rotel.ErrTimeout):
+		self.Logger.Printf("%s is not responding: %v", name, err)
+	case errors.Is(err, rotel.ErrDevice):
+		self.Logger.Printf("%s reported an error: %v", name, err)
+	case errors.Is(err, rotel.ErrFraming):
+		self.Logger.Printf("%s framing error: %v", name, err)
+	case errors.Is(err, rotel.ErrConflict):
+		self.Logger.Printf("%s was changed elsewhere: %v", name, err)
+	case errors.Is(err, rotel.ErrDisconnected):
+		self.Logger.Printf("%s is disconnected: %v", name, err)
+	default:
+		self.Logger.Println(name, "error", err)
+	}
+}
+
+// PublishDevice sets the device for components and publishes them again
+func (self *App) PublishDevice(device *ha.Device, components ...ha.Component) error {
+	for _, component := range components {
+		component.SetDevice(device)
+		if err := self.PublishComponent(component, true); err != nil {
+			return err
+		}
+	}
+
+	// Return success
+	return nil
+}
+
+// PublishHistory publishes the amplifier state history as JSON. The request
+// may contain an RFC3339 time, in which case only later entries are published
+func (self *App) PublishHistory(data []byte) error {
+	var since time.Time
+	if str := strings.TrimSpace(string(data)); str != "" {
+		if t, err := time.Parse(time.RFC3339, str); err != nil {
+			return ErrBadParameter.Withf("history: %q", str)
+		} else {
+			since = t
+		}
+	}
+	var buf bytes.Buffer
+	if err := self.rotel.WriteHistory(&buf, since); err != nil {
+		return err
+	}
+	_, err := self.client.Publish(self.topicHistory, buf.Bytes())
+	return err
+}
+
 func (self *App) PublishComponent(component ha.Component, on bool) error {
 	data, err := component.JSON()
 	if err != nil {
@@ -353,6 +735,8 @@ func (self *App) PublishComponent(component ha.Component, on bool) error {
 	return nil
 }
 
+// StateCallback queues a change of component state, which is published
+// and applied by the run loop
 func (self *App) StateCallback(component ha.Component, data []byte) error {
 	if component == nil || data == nil {
 		return ErrBadParameter.Withf("invalid component or payload data")
@@ -361,7 +745,15 @@ func (self *App) StateCallback(component ha.Component, data []byte) error {
 	if component.SetState(string(data)) {
 		self.Logger.Println("setting component state to", string(data), "for", component.StateTopic())
 		payload := []byte(component.State())
-		self.statech <- StateChange{component, payload}
+		self.mu.Lock()
+		self.changes = append(self.changes, StateChange{component, payload})
+		self.mu.Unlock()
+
+		// Wake the run loop without blocking, as this is called from it
+		select {
+		case self.statech <- struct{}{}:
+		default:
+		}
 	}
 
 	// Return success
@@ -382,6 +774,12 @@ func (self *App) String() string {
 	if self.rotel != nil {
 		str += fmt.Sprintf(" rotel=%v", self.rotel)
 	}
+	if self.cd != nil {
+		str += fmt.Sprintf(" cd=%v", self.cd)
+	}
+	if self.tuner != nil {
+		str += fmt.Sprintf(" tuner=%v", self.tuner)
+	}
 	str += fmt.Sprintf(" qos=%d", self.qos)
 	if self.topic != "" {
 		str += fmt.Sprintf(" topic=%v", self.topic)
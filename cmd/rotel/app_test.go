@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	emulator "github.com/djthorpe/go-rotel/pkg/rotel/emulator"
+	term "github.com/pkg/term"
+	termios "github.com/pkg/term/termios"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// harness runs the app against an in-process broker and an emulated
+// amplifier on a pseudo-terminal
+type harness struct {
+	*broker
+	amp  *emulator.Emulator
+	wire *wire
+}
+
+// wire records the commands received by the emulated amplifier
+type wire struct {
+	io.ReadWriteCloser
+	mu   sync.Mutex
+	data strings.Builder
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	testTopic   = "homeassistant"
+	testId      = "amp00"
+	testHistory = "rotel/history"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_App_001(t *testing.T) {
+	// Discovery configs are published for the amplifier components
+	h := newHarness(t, rotel.State{})
+	for _, topic := range []string{"switch/rotel_amp00_power", "number/rotel_amp00_volume", "select/rotel_amp00_input", "switch/rotel_amp00_speaker_a"} {
+		msg := h.Expect(t, 0, testTopic+"/"+topic+"/config", nil)
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.Payload), &config); err != nil {
+			t.Fatal(topic, err)
+		} else if config["state_topic"] != testTopic+"/"+topic+"/state" {
+			t.Error(topic, "unexpected state topic:", config["state_topic"])
+		}
+		if !msg.Retain {
+			t.Error(topic, "config is not retained")
+		}
+	}
+}
+
+func Test_App_002(t *testing.T) {
+	// Power command is sent to the amplifier, and the state is published
+	h := newHarness(t, rotel.State{})
+	h.Expect(t, 0, testTopic+"/switch/rotel_amp00_power/config", nil)
+	h.Expect(t, 0, testTopic+"/switch/rotel_amp00_power/state", payload("OFF"))
+
+	mark := h.Mark()
+	h.Publish(testTopic+"/switch/rotel_amp00_power/command", "ON")
+	h.Expect(t, mark, testTopic+"/switch/rotel_amp00_power/state", payload("ON"))
+	h.wire.Expect(t, "power_on!")
+	if !h.amp.State().Power {
+		t.Error("amplifier is not powered on")
+	}
+}
+
+func Test_App_003(t *testing.T) {
+	// Volume command is range checked and sent to the amplifier
+	h := newHarness(t, rotel.State{Power: true, Volume: 30, Source: "cd", SpeakerA: true})
+	h.Expect(t, 0, testTopic+"/number/rotel_amp00_volume/state", payload("30"))
+
+	h.Publish(testTopic+"/number/rotel_amp00_volume/command", "45")
+	h.wire.Expect(t, "vol_45!")
+	if volume := h.amp.State().Volume; volume != 45 {
+		t.Error("unexpected volume:", volume)
+	}
+}
+
+func Test_App_004(t *testing.T) {
+	// Front panel changes are published to the state topics
+	h := newHarness(t, rotel.State{Power: true, Volume: 30, Source: "cd", SpeakerA: true})
+	h.Expect(t, 0, testTopic+"/number/rotel_amp00_volume/state", payload("30"))
+	h.wire.Expect(t, "rs232_update_on!")
+
+	mark := h.Mark()
+	if err := h.amp.FrontPanel("opt1"); err != nil {
+		t.Fatal(err)
+	}
+	h.Expect(t, mark, testTopic+"/select/rotel_amp00_input/state", payload("opt1"))
+
+	mark = h.Mark()
+	if err := h.amp.FrontPanel("power_off"); err != nil {
+		t.Fatal(err)
+	}
+	h.Expect(t, mark, testTopic+"/switch/rotel_amp00_power/state", payload("OFF"))
+}
+
+func Test_App_005(t *testing.T) {
+	// Surround processor components are published, and modes and trims
+	// are sent to the processor
+	h := newHarness(t, rotel.State{Model: "RSP-1576", Power: true, Volume: 30, Source: "cd", SpeakerA: true, Mode: "stereo", Format: "pcm"})
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_mode/config", nil)
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_mode/state", payload("stereo"))
+	h.Expect(t, 0, testTopic+"/sensor/rotel_amp00_format/state", payload("pcm"))
+
+	h.Publish(testTopic+"/select/rotel_amp00_mode/command", "dolby_pl2_music")
+	h.wire.Expect(t, "dolby_pl2_music!")
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_mode/state", payload("dolby_pl2_music"))
+
+	h.Publish(testTopic+"/number/rotel_amp00_center/command", "-3")
+	h.wire.Expect(t, "center_-3!")
+	h.Expect(t, 0, testTopic+"/number/rotel_amp00_center/state", payload("-3"))
+	if center := h.amp.State().Center; center != -3 {
+		t.Error("unexpected center trim:", center)
+	}
+}
+
+func Test_App_006(t *testing.T) {
+	// Michi models have a phono mode, which is sent to the amplifier
+	h := newHarness(t, rotel.State{Model: "Michi P5", Power: true, Volume: 100, Source: "phono", SpeakerA: true, PhonoMode: "mm"})
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_phono_mode/config", nil)
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_phono_mode/state", payload("mm"))
+
+	h.Publish(testTopic+"/select/rotel_amp00_phono_mode/command", "mc")
+	h.wire.Expect(t, "phono_mc!")
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_phono_mode/state", payload("mc"))
+	if mode := h.amp.State().PhonoMode; mode != "mc" {
+		t.Error("unexpected phono mode:", mode)
+	}
+}
+
+func Test_App_007(t *testing.T) {
+	// The CD player port is closed when the amplifier cannot be opened
+	b := newBroker(t)
+	ptm, pts, err := termios.Pty()
+	if err != nil {
+		t.Skip("pseudo-terminal not available:", err)
+	}
+	defer ptm.Close()
+
+	if _, err := NewApp(context.Background(), t.Name(), b.Addr(), "", testId, 0, testTopic, "", pts.Name(), "", rotel.Config{TTY: "/dev/rotel-missing"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	pts.Close()
+
+	// Reading from the master side fails once the slave side is closed
+	done := make(chan error)
+	go func() {
+		_, err := ptm.Read(make([]byte, 16))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the CD player port to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("CD player port was not closed")
+	}
+}
+
+func Test_App_008(t *testing.T) {
+	// Values from the state file are published at startup, and replaced as
+	// the amplifier reports them
+	file := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(file, []byte(`{
+		"model": "A14", "power": "on", "volume": "42", "mute": "off", "bass": "+02", "treble": "-01",
+		"balance": ["R", "03"], "source": "opt1", "freq": "off", "bypass": "off", "speaker": "a_b", "dimmer": "2"
+	}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarnessWith(t, rotel.State{Power: true, Volume: 30, Source: "cd", SpeakerA: true}, rotel.Config{StateFile: file})
+	h.Expect(t, 0, testTopic+"/number/rotel_amp00_volume/state", payload("42"))
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_input/state", payload("opt1"))
+	h.Expect(t, 0, testTopic+"/switch/rotel_amp00_speaker_b/state", payload("ON"))
+	h.Expect(t, 0, testTopic+"/number/rotel_amp00_volume/state", payload("30"))
+	h.Expect(t, 0, testTopic+"/select/rotel_amp00_input/state", payload("cd"))
+
+	// Commands are still applied
+	h.Publish(testTopic+"/number/rotel_amp00_volume/command", "45")
+	h.wire.Expect(t, "vol_45!")
+}
+
+func Test_App_009(t *testing.T) {
+	// The state history is published on request, with commands and state
+	// changes but not queries, and only entries after any time requested
+	h := newHarness(t, rotel.State{Power: true, Volume: 30, Source: "cd", SpeakerA: true})
+	h.Expect(t, 0, testTopic+"/number/rotel_amp00_volume/state", payload("30"))
+	h.wire.Expect(t, "rs232_update_on!")
+
+	mark := h.Mark()
+	h.Publish(testHistory+"/get", "")
+	h.Expect(t, mark, testHistory, func(payload string) bool {
+		var entries []map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &entries); err != nil {
+			t.Error(err)
+			return false
+		}
+		var command, volume bool
+		for _, entry := range entries {
+			if cmd, _ := entry["command"].(string); strings.HasSuffix(cmd, "?") {
+				t.Error("unexpected query", cmd)
+			} else if cmd == "rs232_update_on!" {
+				command = true
+			}
+			if state, ok := entry["new"].(map[string]interface{}); ok && state["volume"] == float64(30) {
+				volume = true
+			}
+		}
+		return command && volume
+	})
+
+	// Entries after a time in the future
+	mark = h.Mark()
+	h.Publish(testHistory+"/get", time.Now().Add(time.Hour).Format(time.RFC3339))
+	h.Expect(t, mark, testHistory, func(payload string) bool {
+		return strings.TrimSpace(payload) == "[]"
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// HARNESS
+
+// newHarness starts the broker, the emulated amplifier and the app, which
+// are stopped when the test ends
+func newHarness(t *testing.T, state rotel.State) *harness {
+	t.Helper()
+	return newHarnessWith(t, state, rotel.Config{})
+}
+
+// newHarnessWith starts the app with a driver configuration, where the
+// TTY is replaced by the pseudo-terminal for the emulated amplifier
+func newHarnessWith(t *testing.T, state rotel.State, cfg rotel.Config) *harness {
+	t.Helper()
+	h := new(harness)
+	h.broker = newBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Create a pseudo-terminal for the amplifier
+	ptm, pts, err := termios.Pty()
+	if err != nil {
+		t.Skip("pseudo-terminal not available:", err)
+	}
+	tty, err := term.Open(pts.Name(), term.RawMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.wire = &wire{ReadWriteCloser: ptm}
+	h.amp = emulator.New(h.wire, emulator.Config{Model: state.Model, State: state})
+
+	// Create the app
+	cfg.TTY = pts.Name()
+	app, err := NewApp(ctx, t.Name(), h.Addr(), "", testId, 0, testTopic, testHistory, "", "", cfg)
+	if err != nil {
+		cancel()
+		tty.Close()
+		pts.Close()
+		ptm.Close()
+		t.Fatal(err)
+	}
+
+	// Run the amplifier and the app
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		h.amp.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		if err := app.Run(ctx); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	// Stop when the test ends. The emulator reads until the slave side of
+	// the pseudo-terminal is closed by both the test and the app
+	t.Cleanup(func() {
+		cancel()
+		tty.Close()
+		pts.Close()
+		wg.Wait()
+		ptm.Close()
+	})
+
+	// Return the harness
+	return h
+}
+
+// Read records data read from the pseudo-terminal
+func (w *wire) Read(data []byte) (int, error) {
+	n, err := w.ReadWriteCloser.Read(data)
+	w.mu.Lock()
+	w.data.Write(data[:n])
+	w.mu.Unlock()
+	return n, err
+}
+
+// Expect waits for a command to be received by the amplifier
+func (w *wire) Expect(t *testing.T, cmd string) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w.mu.Lock()
+		found := strings.Contains(w.data.String(), cmd)
+		w.mu.Unlock()
+		if found {
+			return
+		}
+	}
+	t.Fatalf("command %q not received", cmd)
+}
+
+// payload returns a function which matches a payload
+func payload(value string) func(string) bool {
+	return func(payload string) bool {
+		return payload == value
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	// Package imports
 	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
@@ -26,6 +27,19 @@ type Args struct {
 	Id          string
 	Qos         int
 	TTY         string
+	Baud        uint
+	Parity      rotel.Parity
+	DataBits    uint
+	StopBits    uint
+	Flow        rotel.Flow
+	DTR         rotel.Signal
+	RTS         rotel.Signal
+	CDTTY       string
+	TunerTTY    string
+	StateFile   string
+	History     string
+	Defer       time.Duration
+	Strategy    rotel.Strategy
 	Version     bool
 }
 
@@ -71,6 +85,27 @@ func NewArgs(name string, args []string) (*Args, error) {
 	return self, nil
 }
 
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Config returns the amplifier configuration. The serial line settings
+// are shared by the CD player and tuner
+func (self *Args) Config() rotel.Config {
+	return rotel.Config{
+		TTY:       self.TTY,
+		StateFile: self.StateFile,
+		Defer:     self.Defer,
+		Strategy:  self.Strategy,
+		Baud:      self.Baud,
+		Parity:    self.Parity,
+		DataBits:  self.DataBits,
+		StopBits:  self.StopBits,
+		Flow:      self.Flow,
+		DTR:       self.DTR,
+		RTS:       self.RTS,
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // STRINGIFY
 
@@ -89,6 +124,29 @@ func (self *Args) String() string {
 	if self.TTY != "" {
 		str += fmt.Sprintf(" tty=%q", self.TTY)
 	}
+	str += fmt.Sprintf(" baud=%d parity=%v data-bits=%d stop-bits=%d flow=%v", self.Baud, self.Parity, self.DataBits, self.StopBits, self.Flow)
+	if self.DTR != rotel.SIGNAL_DEFAULT {
+		str += fmt.Sprintf(" dtr=%v", self.DTR)
+	}
+	if self.RTS != rotel.SIGNAL_DEFAULT {
+		str += fmt.Sprintf(" rts=%v", self.RTS)
+	}
+	if self.CDTTY != "" {
+		str += fmt.Sprintf(" cd-tty=%q", self.CDTTY)
+	}
+	if self.TunerTTY != "" {
+		str += fmt.Sprintf(" tuner-tty=%q", self.TunerTTY)
+	}
+	if self.StateFile != "" {
+		str += fmt.Sprintf(" state=%q", self.StateFile)
+	}
+	if self.History != "" {
+		str += fmt.Sprintf(" history=%q", self.History)
+	}
+	if self.Defer != 0 {
+		str += fmt.Sprintf(" defer=%v", self.Defer)
+	}
+	str += fmt.Sprintf(" strategy=%v", self.Strategy)
 	str += fmt.Sprintf(" version=%v", self.Version)
 	return str + ">"
 }
@@ -103,5 +161,18 @@ func (self *Args) registerFlags() {
 	self.StringVar(&self.Id, "id", defaultIdentifier, "Unique identifier for Rotel device")
 	self.IntVar(&self.Qos, "qos", 0, "MQTT quality of service")
 	self.StringVar(&self.TTY, "tty", rotel.DEFAULT_TTY, "TTY for Rotel device")
+	self.UintVar(&self.Baud, "baud", rotel.DEFAULT_TTY_BAUD, "Serial port speed")
+	self.Var(&self.Parity, "parity", "Serial port parity: none, odd or even, set by reopening the port")
+	self.UintVar(&self.DataBits, "data-bits", rotel.DEFAULT_TTY_DATABITS, "Serial port data bits, from 5 to 8, set by reopening the port")
+	self.UintVar(&self.StopBits, "stop-bits", rotel.DEFAULT_TTY_STOPBITS, "Serial port stop bits, 1 or 2, set by reopening the port")
+	self.Var(&self.Flow, "flow", "Serial port flow control: none, software or hardware")
+	self.Var(&self.DTR, "dtr", "Serial port DTR line when opened: on, off or default")
+	self.Var(&self.RTS, "rts", "Serial port RTS line when opened: on, off or default")
+	self.StringVar(&self.CDTTY, "cd-tty", "", "TTY for Rotel CD player (optional)")
+	self.StringVar(&self.TunerTTY, "tuner-tty", "", "TTY for Rotel tuner (optional)")
+	self.StringVar(&self.StateFile, "state", "", "File for persisting amplifier state (optional)")
+	self.StringVar(&self.History, "history", "", "Topic for publishing state history on request (optional)")
+	self.DurationVar(&self.Defer, "defer", 0, "Time to queue commands while the amplifier is powering on (optional)")
+	self.Var(&self.Strategy, "strategy", "Update strategy: push, poll or hybrid")
 	self.BoolVar(&self.Version, "version", false, "Print version and exit")
 }
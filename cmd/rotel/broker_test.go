@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// broker is a minimal in-process MQTT 3.1/3.1.1 broker for tests, which
+// supports QoS 0 delivery, retained messages and wildcard subscriptions,
+// and records every message published
+type broker struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	clients  map[*brokerClient]struct{}
+	retained map[string][]byte
+	messages []message
+}
+
+type brokerClient struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	topics map[string]struct{}
+}
+
+type message struct {
+	Topic   string
+	Payload string
+	Retain  bool
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttPubrec      = 5
+	mqttPubrel      = 6
+	mqttPubcomp     = 7
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttUnsubscribe = 10
+	mqttUnsuback    = 11
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttDisconnect  = 14
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// newBroker starts a broker on a local port, which is stopped when the
+// test ends
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := &broker{
+		listener: listener,
+		clients:  make(map[*brokerClient]struct{}),
+		retained: make(map[string][]byte),
+	}
+	self.cond = sync.NewCond(&self.mu)
+
+	// Accept connections
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go self.serve(conn)
+		}
+	}()
+
+	// Stop the broker when the test ends
+	t.Cleanup(func() {
+		listener.Close()
+		self.mu.Lock()
+		for client := range self.clients {
+			client.conn.Close()
+		}
+		self.mu.Unlock()
+	})
+
+	// Return the broker
+	return self
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Addr returns the address of the broker
+func (self *broker) Addr() string {
+	return self.listener.Addr().String()
+}
+
+// Publish sends a message to all subscribers, as if from another client
+func (self *broker) Publish(topic, payload string) {
+	self.publish(message{Topic: topic, Payload: payload})
+}
+
+// Mark returns the number of messages published so far, for use with Expect
+func (self *broker) Mark() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.messages)
+}
+
+// Expect waits for a message on a topic published after the mark, and
+// returns it. If match is not nil, the payload must also match
+func (self *broker) Expect(t *testing.T, mark int, topic string, match func(string) bool) message {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+
+	// Wake waiters at the deadline
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		self.mu.Lock()
+		defer self.mu.Unlock()
+		self.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for {
+		for _, msg := range self.messages[mark:] {
+			if msg.Topic == topic && (match == nil || match(msg.Payload)) {
+				return msg
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no message on %q", topic)
+		}
+		self.cond.Wait()
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// serve reads packets from a client until disconnected
+func (self *broker) serve(conn net.Conn) {
+	client := &brokerClient{conn: conn, topics: make(map[string]struct{})}
+	self.mu.Lock()
+	self.clients[client] = struct{}{}
+	self.mu.Unlock()
+	defer func() {
+		self.mu.Lock()
+		delete(self.clients, client)
+		self.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case mqttConnect:
+			client.write(mqttConnack<<4, []byte{0, 0})
+		case mqttPublish:
+			qos := (header >> 1) & 3
+			topic, rest := readString(body)
+			if qos > 0 {
+				id := rest[:2]
+				rest = rest[2:]
+				if qos == 1 {
+					client.write(mqttPuback<<4, id)
+				} else {
+					client.write(mqttPubrec<<4, id)
+				}
+			}
+			self.publish(message{Topic: topic, Payload: string(rest), Retain: header&1 == 1})
+		case mqttPubrel:
+			client.write(mqttPubcomp<<4, body[:2])
+		case mqttSubscribe:
+			id, rest := body[:2], body[2:]
+			var granted []string
+			for len(rest) > 0 {
+				var topic string
+				topic, rest = readString(rest)
+				rest = rest[1:]
+				granted = append(granted, topic)
+				client.mu.Lock()
+				client.topics[topic] = struct{}{}
+				client.mu.Unlock()
+			}
+			client.write(mqttSuback<<4, append(id, make([]byte, len(granted))...))
+			self.sendRetained(client, granted)
+		case mqttUnsubscribe:
+			id, rest := body[:2], body[2:]
+			for len(rest) > 0 {
+				var topic string
+				topic, rest = readString(rest)
+				client.mu.Lock()
+				delete(client.topics, topic)
+				client.mu.Unlock()
+			}
+			client.write(mqttUnsuback<<4, id)
+		case mqttPingreq:
+			client.write(mqttPingresp<<4, nil)
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+// publish records a message, keeps it when retained, and sends it to
+// subscribers
+func (self *broker) publish(msg message) {
+	self.mu.Lock()
+	self.messages = append(self.messages, msg)
+	if msg.Retain {
+		if msg.Payload == "" {
+			delete(self.retained, msg.Topic)
+		} else {
+			self.retained[msg.Topic] = []byte(msg.Payload)
+		}
+	}
+	clients := make([]*brokerClient, 0, len(self.clients))
+	for client := range self.clients {
+		clients = append(clients, client)
+	}
+	self.cond.Broadcast()
+	self.mu.Unlock()
+
+	for _, client := range clients {
+		if client.subscribed(msg.Topic) {
+			client.send(msg.Topic, []byte(msg.Payload), false)
+		}
+	}
+}
+
+// sendRetained sends retained messages which match new subscriptions
+func (self *broker) sendRetained(client *brokerClient, filters []string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for topic, payload := range self.retained {
+		for _, filter := range filters {
+			if topicMatch(filter, topic) {
+				client.send(topic, payload, true)
+				break
+			}
+		}
+	}
+}
+
+func (client *brokerClient) subscribed(topic string) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for filter := range client.topics {
+		if topicMatch(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// send writes a QoS 0 publish packet
+func (client *brokerClient) send(topic string, payload []byte, retain bool) {
+	header := byte(mqttPublish << 4)
+	if retain {
+		header |= 1
+	}
+	body := append(writeString(topic), payload...)
+	client.write(header, body)
+}
+
+func (client *brokerClient) write(header byte, body []byte) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	packet := []byte{header}
+	for n := len(body); ; {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	client.conn.Write(append(packet, body...))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// UTILITY FUNCTIONS
+
+// readPacket returns the fixed header byte and the body of a packet
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var length, shift uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= uint(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func readString(data []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(data))
+	return string(data[2 : 2+n]), data[2+n:]
+}
+
+func writeString(value string) []byte {
+	data := make([]byte, 2, 2+len(value))
+	binary.BigEndian.PutUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
+
+// topicMatch returns true if a topic matches a filter with + and #
+// wildcards
+func topicMatch(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, part := range f {
+		switch {
+		case part == "#":
+			return true
+		case i >= len(t):
+			return false
+		case part != "+" && part != t[i]:
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
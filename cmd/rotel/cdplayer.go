@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	// Package imports
+	ha "github.com/djthorpe/go-rotel/pkg/ha"
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type CDPlayer struct {
+	Power  ha.Component
+	Play   ha.Component
+	Stop   ha.Component
+	Pause  ha.Component
+	Eject  ha.Component
+	Next   ha.Component
+	Prev   ha.Component
+	Status ha.Component
+	Track  ha.Component
+	Time   ha.Component
+	Disc   ha.Component
+	Repeat ha.Component
+	Random ha.Component
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// AddCDPlayer adds and publishes the CD player components
+func (self *App) AddCDPlayer() (*CDPlayer, error) {
+	var err error
+	cdplayer := new(CDPlayer)
+	prefix := self.id + "_cd"
+
+	// Add power switch
+	if cdplayer.Power, err = self.ha.AddSwitch(prefix, "power", "CD Power", "mdi:power"); err != nil {
+		return nil, err
+	}
+
+	// Add transport buttons
+	if cdplayer.Play, err = self.ha.AddButton(prefix, "play", "CD Play", "mdi:play"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Stop, err = self.ha.AddButton(prefix, "stop", "CD Stop", "mdi:stop"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Pause, err = self.ha.AddButton(prefix, "pause", "CD Pause", "mdi:pause"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Eject, err = self.ha.AddButton(prefix, "eject", "CD Eject", "mdi:eject"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Next, err = self.ha.AddButton(prefix, "next", "CD Next Track", "mdi:skip-next"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Prev, err = self.ha.AddButton(prefix, "prev", "CD Previous Track", "mdi:skip-previous"); err != nil {
+		return nil, err
+	}
+
+	// Add play status, track, time and disc status
+	if cdplayer.Status, err = self.ha.AddSensor(prefix, "status", "CD Status"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Track, err = self.ha.AddSensor(prefix, "track", "CD Track"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Time, err = self.ha.AddSensor(prefix, "time", "CD Time"); err != nil {
+		return nil, err
+	}
+	if cdplayer.Disc, err = self.ha.AddSensor(prefix, "disc", "CD Disc"); err != nil {
+		return nil, err
+	}
+
+	// Add repeat and random
+	if cdplayer.Repeat, err = self.ha.AddSelect(prefix, "repeat", "CD Repeat", rotel.REPEAT_MODES); err != nil {
+		return nil, err
+	}
+	if cdplayer.Random, err = self.ha.AddSwitch(prefix, "random", "CD Random", "mdi:shuffle-variant"); err != nil {
+		return nil, err
+	}
+
+	// Publish components
+	for _, component := range []ha.Component{
+		cdplayer.Power, cdplayer.Play, cdplayer.Stop, cdplayer.Pause, cdplayer.Eject, cdplayer.Next, cdplayer.Prev,
+		cdplayer.Status, cdplayer.Track, cdplayer.Time, cdplayer.Disc, cdplayer.Repeat, cdplayer.Random,
+	} {
+		if err := self.PublishComponent(component, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Return success
+	return cdplayer, nil
+}
+
+// CDPlayerCommand sends a command to the CD player when a component
+// is changed from home assistant
+func (self *App) CDPlayerCommand(component ha.Component, data []byte) {
+	var err error
+	switch component {
+	case self.cdplayer.Power:
+		err = self.cd.SetPower(string(data) == "ON")
+	case self.cdplayer.Play:
+		err = self.cd.Play()
+	case self.cdplayer.Stop:
+		err = self.cd.Stop()
+	case self.cdplayer.Pause:
+		err = self.cd.Pause()
+	case self.cdplayer.Eject:
+		err = self.cd.Eject()
+	case self.cdplayer.Next:
+		err = self.cd.NextTrack()
+	case self.cdplayer.Prev:
+		err = self.cd.PrevTrack()
+	case self.cdplayer.Repeat:
+		err = self.cd.SetRepeat(string(data))
+	case self.cdplayer.Random:
+		err = self.cd.SetRandom(string(data) == "ON")
+	}
+	if err != nil {
+		self.CommandError("CD player", err)
+	}
+}
+
+// CDPlayerEvent updates the component state when the CD player state changes
+func (self *App) CDPlayerEvent(evt rotel.Event) {
+	state, _ := evt.New.(rotel.CDState)
+	if evt.Err != nil {
+		self.EventError("cd player", evt.Err)
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_MODEL) {
+		self.Logger.Println("cd player model=", state.Model)
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_POWER) {
+		self.StateCallback(self.cdplayer.Power, []byte(onOff(state.Power)))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_POWER) || evt.Flag.Is(rotel.ROTEL_FLAG_PLAY) {
+		self.StateCallback(self.cdplayer.Status, []byte(state.Status))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_TRACK) {
+		str := fmt.Sprintf("%d", state.Track)
+		self.StateCallback(self.cdplayer.Track, []byte(str))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_TIME) {
+		t := state.Time.Truncate(time.Second)
+		str := fmt.Sprintf("%d:%02d", int(t.Minutes()), int(t.Seconds())%60)
+		self.StateCallback(self.cdplayer.Time, []byte(str))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_DISC) {
+		self.StateCallback(self.cdplayer.Disc, []byte(state.Disc))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_REPEAT) {
+		self.StateCallback(self.cdplayer.Repeat, []byte(state.Repeat))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_RANDOM) {
+		self.StateCallback(self.cdplayer.Random, []byte(onOff(state.Random)))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func onOff(state bool) string {
+	if state {
+		return "ON"
+	} else {
+		return "OFF"
+	}
+}
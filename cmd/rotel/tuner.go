@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	// Package imports
+	ha "github.com/djthorpe/go-rotel/pkg/ha"
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Radio are the home assistant components for a tuner
+type Radio struct {
+	Power     ha.Component
+	Band      ha.Component
+	Preset    ha.Component
+	Store     ha.Component
+	Frequency ha.Component
+	Text      ha.Component
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// AddRadio adds and publishes the tuner components
+func (self *App) AddRadio() (*Radio, error) {
+	var err error
+	radio := new(Radio)
+	prefix := self.id + "_tuner"
+
+	// Add power switch
+	if radio.Power, err = self.ha.AddSwitch(prefix, "power", "Tuner Power", "mdi:power"); err != nil {
+		return nil, err
+	}
+
+	// Add band and presets
+	if radio.Band, err = self.ha.AddSelect(prefix, "band", "Tuner Band", rotel.BANDS); err != nil {
+		return nil, err
+	}
+	presets := make([]string, 0, rotel.PRESET_MAX)
+	for preset := rotel.PRESET_MIN; preset <= rotel.PRESET_MAX; preset++ {
+		presets = append(presets, fmt.Sprint(preset))
+	}
+	if radio.Preset, err = self.ha.AddSelect(prefix, "preset", "Tuner Preset", presets); err != nil {
+		return nil, err
+	}
+	if radio.Store, err = self.ha.AddSelect(prefix, "store", "Tuner Store Preset", presets); err != nil {
+		return nil, err
+	}
+
+	// Add frequency, with the FM range until the band is known
+	if radio.Frequency, err = self.ha.AddSlider(prefix, "frequency", "Tuner Frequency"); err != nil {
+		return nil, err
+	}
+	setFrequencyRange(radio.Frequency, "fm")
+
+	// Add station text
+	if radio.Text, err = self.ha.AddSensor(prefix, "text", "Tuner Station"); err != nil {
+		return nil, err
+	}
+
+	// Publish components
+	for _, component := range []ha.Component{radio.Power, radio.Band, radio.Preset, radio.Store, radio.Frequency, radio.Text} {
+		if err := self.PublishComponent(component, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Return success
+	return radio, nil
+}
+
+// RadioCommand sends a command to the tuner when a component
+// is changed from home assistant
+func (self *App) RadioCommand(component ha.Component, data []byte) {
+	var err error
+	switch component {
+	case self.radio.Power:
+		err = self.tuner.SetPower(string(data) == "ON")
+	case self.radio.Band:
+		err = self.tuner.SetBand(string(data))
+	case self.radio.Preset:
+		if value, err_ := strconv.ParseUint(string(data), 10, 32); err_ != nil {
+			err = err_
+		} else {
+			err = self.tuner.RecallPreset(uint(value))
+		}
+	case self.radio.Store:
+		if value, err_ := strconv.ParseUint(string(data), 10, 32); err_ != nil {
+			err = err_
+		} else {
+			err = self.tuner.StorePreset(uint(value))
+		}
+	case self.radio.Frequency:
+		if value, err_ := strconv.ParseFloat(string(data), 64); err_ != nil {
+			err = err_
+		} else {
+			err = self.tuner.SetFrequency(value)
+		}
+	}
+	if err != nil {
+		self.CommandError("tuner", err)
+	}
+}
+
+// RadioEvent updates the component state when the tuner state changes
+func (self *App) RadioEvent(evt rotel.Event) {
+	state, _ := evt.New.(rotel.TunerState)
+	if evt.Err != nil {
+		self.EventError("tuner", evt.Err)
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_MODEL) {
+		self.Logger.Println("tuner model=", state.Model)
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_POWER) {
+		self.StateCallback(self.radio.Power, []byte(onOff(state.Power)))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_BAND) {
+		self.StateCallback(self.radio.Band, []byte(state.Band))
+
+		// Publish the frequency range for the band
+		if setFrequencyRange(self.radio.Frequency, state.Band) {
+			if err := self.PublishComponent(self.radio.Frequency, true); err != nil {
+				self.EventError("tuner", err)
+			}
+		}
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_PRESET) && state.Preset != 0 {
+		self.StateCallback(self.radio.Preset, []byte(fmt.Sprint(state.Preset)))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_FREQ) && state.Frequency != 0 {
+		self.StateCallback(self.radio.Frequency, []byte(strconv.FormatFloat(state.Frequency, 'f', -1, 64)))
+	}
+	if evt.Flag.Is(rotel.ROTEL_FLAG_TEXT) {
+		self.StateCallback(self.radio.Text, []byte(state.Text))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// setFrequencyRange sets the range and step of the frequency slider for
+// FM in MHz or AM in kHz, and returns false for other bands
+func setFrequencyRange(component ha.Component, band string) bool {
+	slider := component.(*ha.Slider)
+	switch band {
+	case "fm":
+		slider.SetRange(rotel.FM_MIN, rotel.FM_MAX)
+		slider.SetStep(rotel.FM_STEP)
+	case "am":
+		slider.SetRange(rotel.AM_MIN, rotel.AM_MAX)
+		slider.SetStep(rotel.AM_STEP)
+	default:
+		return false
+	}
+	return true
+}
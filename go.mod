@@ -6,4 +6,5 @@ require (
 	github.com/djthorpe/go-errors v1.0.2
 	github.com/mutablelogic/go-mosquitto v1.0.8
 	github.com/pkg/term v1.1.0
+	golang.org/x/sys v0.0.0-20210930141918-969570ce7c6c
 )
@@ -0,0 +1,39 @@
+package ha
+
+import (
+	"encoding/json"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Button struct {
+	component
+	Icon string `json:"icon,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewButton(topic, Id, objectId, name, icon string) (*Button, error) {
+	self := new(Button)
+	if err := self.Init(topic, "button", Id, objectId, name, false, true); err != nil {
+		return nil, err
+	}
+	self.Icon = icon
+
+	// Return success
+	return self, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// A button has no state, so every press is a change
+func (self *Button) SetState(v string) bool {
+	return true
+}
+
+func (self *Button) JSON() ([]byte, error) {
+	return json.Marshal(self)
+}
@@ -26,17 +26,21 @@ type Component interface {
 	// Return the state of the component as a string
 	State() string
 
+	// Set the device for the component
+	SetDevice(*Device)
+
 	// Return JSON representation of component configuration
 	JSON() ([]byte, error)
 }
 
 type component struct {
-	Id_           string `json:"unique_id,omitempty"`
-	ObjectId      string `json:"object_id,omitempty"`
-	Name          string `json:"name,omitempty"`
-	ConfigTopic_  string `json:"-"`
-	CommandTopic_ string `json:"command_topic,omitempty"`
-	StateTopic_   string `json:"state_topic,omitempty"`
+	Id_           string  `json:"unique_id,omitempty"`
+	ObjectId      string  `json:"object_id,omitempty"`
+	Name          string  `json:"name,omitempty"`
+	ConfigTopic_  string  `json:"-"`
+	CommandTopic_ string  `json:"command_topic,omitempty"`
+	StateTopic_   string  `json:"state_topic,omitempty"`
+	Device        *Device `json:"device,omitempty"`
 
 	// The state of the component
 	state string `json:"-"`
@@ -97,3 +101,8 @@ func (self *component) SetState(v string) bool {
 func (self *component) State() string {
 	return self.state
 }
+
+// Set the device for the component
+func (self *component) SetDevice(device *Device) {
+	self.Device = device
+}
@@ -0,0 +1,25 @@
+package ha
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Device groups components in home assistant, and reports the manufacturer
+// and model in discovery
+type Device struct {
+	Identifiers  []string `json:"identifiers,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewDevice(id, manufacturer, model string) *Device {
+	return &Device{
+		Identifiers:  []string{id},
+		Name:         manufacturer + " " + model,
+		Manufacturer: manufacturer,
+		Model:        model,
+	}
+}
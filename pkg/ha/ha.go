@@ -109,63 +109,57 @@ func (self *HA) SetStatus(v string) error {
 }
 
 func (self *HA) AddPowerButton(prefix, suffix string) (Component, error) {
-	object_id := strings.ToLower(prefix + "_" + suffix)
-	component, err := NewPowerButton(self.topic, object_id, object_id)
-	if err != nil {
-		return nil, err
-	}
-	if err := self.AddComponent(component); err != nil {
-		return nil, err
-	}
-	return component, nil
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewPowerButton(self.topic, objectId, objectId)
+	})
 }
 
 func (self *HA) AddSpeaker(prefix, suffix string, speakerName string) (Component, error) {
-	object_id := strings.ToLower(prefix + "_" + suffix)
-	component, err := NewSpeaker(self.topic, object_id, object_id, speakerName)
-	if err != nil {
-		return nil, err
-	}
-	if err := self.AddComponent(component); err != nil {
-		return nil, err
-	}
-	return component, nil
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewSpeaker(self.topic, objectId, objectId, speakerName)
+	})
 }
 
 func (self *HA) AddVolume(prefix, suffix string) (Component, error) {
-	object_id := strings.ToLower(prefix + "_" + suffix)
-	component, err := NewVolume(self.topic, object_id, object_id)
-	if err != nil {
-		return nil, err
-	}
-	if err := self.AddComponent(component); err != nil {
-		return nil, err
-	}
-	return component, nil
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewVolume(self.topic, objectId, objectId)
+	})
 }
 
 func (self *HA) AddSlider(prefix, suffix string, name string) (Component, error) {
-	object_id := strings.ToLower(prefix + "_" + suffix)
-	component, err := NewSlider(self.topic, object_id, object_id, name)
-	if err != nil {
-		return nil, err
-	}
-	if err := self.AddComponent(component); err != nil {
-		return nil, err
-	}
-	return component, nil
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewSlider(self.topic, objectId, objectId, name)
+	})
 }
 
 func (self *HA) AddInput(prefix, suffix string, options []string) (Component, error) {
-	object_id := strings.ToLower(prefix + "_" + suffix)
-	component, err := NewInput(self.topic, object_id, object_id, options)
-	if err != nil {
-		return nil, err
-	}
-	if err := self.AddComponent(component); err != nil {
-		return nil, err
-	}
-	return component, nil
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewInput(self.topic, objectId, objectId, options)
+	})
+}
+
+func (self *HA) AddSelect(prefix, suffix string, name string, options []string) (Component, error) {
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewSelect(self.topic, objectId, objectId, name, options)
+	})
+}
+
+func (self *HA) AddSensor(prefix, suffix string, name string) (Component, error) {
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewSensor(self.topic, objectId, objectId, name)
+	})
+}
+
+func (self *HA) AddButton(prefix, suffix string, name, icon string) (Component, error) {
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewButton(self.topic, objectId, objectId, name, icon)
+	})
+}
+
+func (self *HA) AddSwitch(prefix, suffix string, name, icon string) (Component, error) {
+	return self.add(prefix, suffix, func(objectId string) (Component, error) {
+		return NewSwitch(self.topic, objectId, objectId, name, icon)
+	})
 }
 
 func (self *HA) AddComponent(component Component) error {
@@ -212,6 +206,19 @@ func (self *HA) Components() []Component {
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// add creates a component with an object id made from the prefix and
+// suffix, and adds it
+func (self *HA) add(prefix, suffix string, fn func(objectId string) (Component, error)) (Component, error) {
+	component, err := fn(strings.ToLower(prefix + "_" + suffix))
+	if err != nil {
+		return nil, err
+	}
+	if err := self.AddComponent(component); err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
 func topicName(topic string, parts ...string) string {
 	return strings.Join(append([]string{topic}, parts...), topicSeparator)
 }
@@ -0,0 +1,141 @@
+package ha_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+
+	// Package imports
+	ha "github.com/djthorpe/go-rotel/pkg/ha"
+
+	// Namespace imports
+	goerrors "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_HA_001(t *testing.T) {
+	// The discovery config of each component type has the topics, device
+	// and fields expected, and no topics which the component does not use
+	self, err := ha.New("homeassistant", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := ha.NewDevice("rotel_amp00", "Rotel", "A14")
+	tests := []struct {
+		name    string
+		add     func() (ha.Component, error)
+		config  string
+		fields  map[string]interface{}
+		absent  []string
+		command bool
+		state   bool
+	}{
+		{"power", func() (ha.Component, error) { return self.AddPowerButton("rotel", "power") }, "switch/rotel_power", map[string]interface{}{"name": "Power", "icon": "mdi:power"}, nil, true, true},
+		{"speaker", func() (ha.Component, error) { return self.AddSpeaker("rotel", "speaker_a", "Speaker A") }, "switch/rotel_speaker_a", map[string]interface{}{"name": "Speaker A"}, nil, true, true},
+		{"volume", func() (ha.Component, error) { return self.AddVolume("rotel", "volume") }, "number/rotel_volume", map[string]interface{}{"name": "Volume", "icon": "mdi:volume-high"}, []string{"min", "max"}, true, true},
+		{"slider", func() (ha.Component, error) { return self.AddSlider("rotel", "center", "Center") }, "number/rotel_center", map[string]interface{}{"name": "Center", "icon": "mdi:tune-variant"}, []string{"min", "max", "step"}, true, true},
+		{"input", func() (ha.Component, error) { return self.AddInput("rotel", "input", []string{"cd", "opt1"}) }, "select/rotel_input", map[string]interface{}{"name": "Input", "options": []interface{}{"cd", "opt1"}}, nil, true, true},
+		{"select", func() (ha.Component, error) {
+			return self.AddSelect("rotel", "mode", "Mode", []string{"stereo", "dolby_pl2_music"})
+		}, "select/rotel_mode", map[string]interface{}{"name": "Mode", "icon": "mdi:format-list-bulleted", "options": []interface{}{"stereo", "dolby_pl2_music"}}, nil, true, true},
+		{"sensor", func() (ha.Component, error) { return self.AddSensor("rotel", "format", "Format") }, "sensor/rotel_format", map[string]interface{}{"name": "Format", "icon": "mdi:information-outline"}, []string{"options"}, false, true},
+		{"button", func() (ha.Component, error) { return self.AddButton("rotel", "play", "Play", "mdi:play") }, "button/rotel_play", map[string]interface{}{"name": "Play", "icon": "mdi:play"}, nil, true, false},
+		{"switch", func() (ha.Component, error) { return self.AddSwitch("rotel", "random", "Random", "mdi:shuffle") }, "switch/rotel_random", map[string]interface{}{"name": "Random", "icon": "mdi:shuffle"}, nil, true, true},
+	}
+	for _, test := range tests {
+		component, err := test.add()
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		component.SetDevice(device)
+		data, err := component.JSON()
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		var config map[string]interface{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatal(test.name, err)
+		}
+
+		// Topics
+		topic := "homeassistant/" + test.config
+		if component.ConfigTopic() != topic+"/config" {
+			t.Errorf("%s: unexpected config topic %q", test.name, component.ConfigTopic())
+		}
+		for key, expected := range map[string]bool{"command_topic": test.command, "state_topic": test.state} {
+			suffix := "/command"
+			if key == "state_topic" {
+				suffix = "/state"
+			}
+			if value, exists := config[key]; exists != expected {
+				t.Errorf("%s: unexpected %s %v", test.name, key, value)
+			} else if exists && value != topic+suffix {
+				t.Errorf("%s: unexpected %s %v", test.name, key, value)
+			}
+		}
+
+		// Identifiers
+		if id := path.Base(test.config); config["unique_id"] != id || config["object_id"] != id || component.Id() != id {
+			t.Errorf("%s: unexpected ids %v, %v", test.name, config["unique_id"], config["object_id"])
+		}
+
+		// Device
+		if expected := map[string]interface{}{
+			"identifiers":  []interface{}{"rotel_amp00"},
+			"name":         "Rotel A14",
+			"manufacturer": "Rotel",
+			"model":        "A14",
+		}; !reflect.DeepEqual(config["device"], expected) {
+			t.Errorf("%s: unexpected device %v", test.name, config["device"])
+		}
+
+		// Fields
+		for key, expected := range test.fields {
+			if !reflect.DeepEqual(config[key], expected) {
+				t.Errorf("%s: %s: expected %v, got %v", test.name, key, expected, config[key])
+			}
+		}
+		for _, key := range test.absent {
+			if value, exists := config[key]; exists {
+				t.Errorf("%s: unexpected %s %v", test.name, key, value)
+			}
+		}
+	}
+}
+
+func Test_HA_002(t *testing.T) {
+	// Components are found by their command topic, and a component cannot
+	// be added twice
+	var changed ha.Component
+	self, err := ha.New("homeassistant", func(component ha.Component, data []byte) error {
+		changed = component
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	component, err := self.AddSwitch("Rotel", "Mute", "Mute", "mdi:volume-off")
+	if err != nil {
+		t.Fatal(err)
+	} else if component.Id() != "rotel_mute" {
+		t.Errorf("unexpected id %q", component.Id())
+	}
+	if _, err := self.AddSwitch("rotel", "mute", "Mute", "mdi:volume-off"); !errors.Is(err, goerrors.ErrDuplicateEntry) {
+		t.Error("unexpected error:", err)
+	}
+	if err := self.Command(component.CommandTopic(), []byte("ON")); err != nil {
+		t.Error(err)
+	} else if changed != component {
+		t.Error("unexpected component", changed)
+	}
+	if err := self.Command("homeassistant/switch/rotel_missing/command", nil); !errors.Is(err, goerrors.ErrNotFound) {
+		t.Error("unexpected error:", err)
+	}
+	if len(self.Components()) != 1 {
+		t.Error("unexpected components", self.Components())
+	}
+}
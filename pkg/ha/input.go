@@ -31,6 +31,10 @@ func NewInput(topic, Id, objectId string, options []string) (*Input, error) {
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+func (self *Input) SetOptions(options []string) {
+	self.Options = options
+}
+
 func (self *Input) JSON() ([]byte, error) {
 	return json.Marshal(self)
 }
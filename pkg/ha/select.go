@@ -0,0 +1,36 @@
+package ha
+
+import (
+	"encoding/json"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Select struct {
+	component
+	Icon    string   `json:"icon,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewSelect(topic, Id, objectId, name string, options []string) (*Select, error) {
+	self := new(Select)
+	if err := self.Init(topic, "select", Id, objectId, name, true, true); err != nil {
+		return nil, err
+	}
+	self.Icon = "mdi:format-list-bulleted"
+	self.Options = options
+
+	// Return success
+	return self, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (self *Select) JSON() ([]byte, error) {
+	return json.Marshal(self)
+}
@@ -0,0 +1,34 @@
+package ha
+
+import (
+	"encoding/json"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Sensor struct {
+	component
+	Icon string `json:"icon,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewSensor(topic, Id, objectId, name string) (*Sensor, error) {
+	self := new(Sensor)
+	if err := self.Init(topic, "sensor", Id, objectId, name, true, false); err != nil {
+		return nil, err
+	}
+	self.Icon = "mdi:information-outline"
+
+	// Return success
+	return self, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (self *Sensor) JSON() ([]byte, error) {
+	return json.Marshal(self)
+}
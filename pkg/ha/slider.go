@@ -12,6 +12,7 @@ type Slider struct {
 	Icon string   `json:"icon,omitempty"`
 	Min  *float32 `json:"min,omitempty"`
 	Max  *float32 `json:"max,omitempty"`
+	Step *float32 `json:"step,omitempty"`
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -36,6 +37,10 @@ func (self *Slider) SetRange(min, max float32) {
 	self.Max = &max
 }
 
+func (self *Slider) SetStep(step float32) {
+	self.Step = &step
+}
+
 func (self *Slider) JSON() ([]byte, error) {
 	return json.Marshal(self)
 }
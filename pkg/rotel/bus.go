@@ -0,0 +1,163 @@
+package rotel
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// bus delivers events to any number of subscribers
+type bus struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber buffers events for one receiver. When the buffer is full,
+// further events are coalesced into a single pending event rather than
+// dropped
+type subscriber struct {
+	mu      sync.Mutex
+	filter  Flag
+	ch      chan Event
+	notify  chan struct{}
+	pending *Event
+	busy    bool // Pending event taken but not yet delivered
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	subscriberBuffer = 16
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newBus() *bus {
+	return &bus{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Subscribe returns a channel of events which have any of the flags in
+// filter set, or all events when filter is ROTEL_FLAG_NONE. Events with
+// errors are always delivered. When the receiver is slow, events are
+// coalesced: the flags are combined, Old is kept from the first event and
+// New is taken from the last. The channel is closed when the context is
+// cancelled
+func (self *bus) Subscribe(ctx context.Context, filter Flag) <-chan Event {
+	s := &subscriber{
+		filter: filter,
+		ch:     make(chan Event, subscriberBuffer),
+		notify: make(chan struct{}, 1),
+	}
+
+	// Add the subscriber
+	self.mu.Lock()
+	self.subscribers[s] = struct{}{}
+	self.mu.Unlock()
+
+	// Deliver events until the context is cancelled
+	go func() {
+		defer close(s.ch)
+		defer func() {
+			self.mu.Lock()
+			delete(self.subscribers, s)
+			self.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+				if evt := s.take(); evt != nil {
+					select {
+					case s.ch <- *evt:
+						s.delivered()
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	// Return the channel
+	return s.ch
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// publish sends an event to all subscribers
+func (self *bus) publish(evt Event) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for s := range self.subscribers {
+		if s.filter == ROTEL_FLAG_NONE || s.filter&evt.Flag != 0 || evt.Err != nil {
+			s.put(evt)
+		}
+	}
+}
+
+// put adds an event to the pending event, and wakes the subscriber
+func (s *subscriber) put(evt Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Send directly when nothing is pending and there is room
+	if s.pending == nil && !s.busy {
+		select {
+		case s.ch <- evt:
+			return
+		default:
+			s.pending = &evt
+		}
+	} else if s.pending == nil {
+		s.pending = &evt
+	} else {
+		s.pending.Flag |= evt.Flag
+		s.pending.Time = evt.Time
+		if s.pending.Old == nil {
+			s.pending.Old = evt.Old
+		}
+		if evt.New != nil {
+			s.pending.New = evt.New
+		}
+		s.pending.Pending = evt.Pending
+		if evt.Err != nil {
+			s.pending.Err = errors.Join(s.pending.Err, evt.Err)
+		}
+	}
+
+	// Wake the subscriber
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending event and clears it
+func (s *subscriber) take() *Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evt := s.pending
+	s.pending = nil
+	s.busy = evt != nil
+	return evt
+}
+
+// delivered is called when a taken event has been sent, so that events
+// are sent directly again
+func (s *subscriber) delivered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = false
+}
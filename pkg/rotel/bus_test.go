@@ -0,0 +1,123 @@
+package rotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Bus_001(t *testing.T) {
+	// Events are coalesced when the receiver is not ready: Old is from the
+	// first event, New from the last, flags are combined and errors joined
+	s := &subscriber{ch: make(chan Event), notify: make(chan struct{}, 1)}
+	errMute, errBass := errors.New("mute"), errors.New("bass")
+	s.put(Event{Flag: ROTEL_FLAG_VOLUME, Old: State{Volume: 1}, New: State{Volume: 2}})
+	s.put(Event{Flag: ROTEL_FLAG_MUTE, Err: errMute, Old: State{Volume: 2}, New: State{Volume: 3, Mute: true}})
+	s.put(Event{Err: errBass})
+	s.put(Event{Flag: ROTEL_FLAG_BASS, Old: State{Volume: 3, Mute: true}, New: State{Volume: 4, Mute: true, Bass: 2}})
+
+	evt := s.take()
+	if evt == nil {
+		t.Fatal("expected a pending event")
+	}
+	if evt.Flag != ROTEL_FLAG_VOLUME|ROTEL_FLAG_MUTE|ROTEL_FLAG_BASS {
+		t.Error("unexpected flags", evt.Flag)
+	}
+	if evt.Old != (State{Volume: 1}) {
+		t.Error("unexpected old state", evt.Old)
+	}
+	if evt.New != (State{Volume: 4, Mute: true, Bass: 2}) {
+		t.Error("unexpected new state", evt.New)
+	}
+	if !errors.Is(evt.Err, errMute) || !errors.Is(evt.Err, errBass) {
+		t.Error("unexpected error", evt.Err)
+	}
+	if s.take() != nil {
+		t.Error("unexpected pending event")
+	}
+}
+
+func Test_Bus_002(t *testing.T) {
+	// A slow receiver gets every change, ending with the latest state
+	b := newBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := b.Subscribe(ctx, ROTEL_FLAG_NONE)
+
+	const n = 4 * subscriberBuffer
+	for i := 1; i <= n; i++ {
+		b.publish(Event{Flag: ROTEL_FLAG_VOLUME, Old: State{Volume: uint(i - 1)}, New: State{Volume: uint(i)}})
+	}
+	b.publish(Event{Flag: ROTEL_FLAG_MUTE, Old: State{Volume: n}, New: State{Volume: n, Mute: true}})
+
+	var flags Flag
+	var count int
+	var last Snapshot
+	for last != (State{Volume: n, Mute: true}) {
+		select {
+		case evt := <-ch:
+			flags |= evt.Flag
+			last = evt.New
+			count++
+		case <-time.After(time.Second):
+			t.Fatal("missing events, last state", last)
+		}
+	}
+	if flags != ROTEL_FLAG_VOLUME|ROTEL_FLAG_MUTE {
+		t.Error("unexpected flags", flags)
+	}
+	if count > n {
+		t.Error("events were not coalesced", count)
+	}
+}
+
+func Test_Bus_003(t *testing.T) {
+	// Events are filtered on flags, except events with errors
+	b := newBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := b.Subscribe(ctx, ROTEL_FLAG_POWER)
+
+	b.publish(Event{Flag: ROTEL_FLAG_VOLUME})
+	b.publish(Event{Err: ErrTimeout})
+	b.publish(Event{Flag: ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME})
+	for _, expect := range []Event{{Err: ErrTimeout}, {Flag: ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME}} {
+		select {
+		case evt := <-ch:
+			if evt.Flag != expect.Flag || evt.Err != expect.Err {
+				t.Errorf("expected %v, got %v", expect, evt)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("missing event", expect)
+		}
+	}
+}
+
+func Test_Bus_004(t *testing.T) {
+	// The channel is closed when the context is cancelled, and events are
+	// no longer delivered
+	b := newBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := b.Subscribe(ctx, ROTEL_FLAG_NONE)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("unexpected event")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed")
+	}
+	b.publish(Event{Flag: ROTEL_FLAG_POWER})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscribers) != 0 {
+		t.Error("subscriber was not removed")
+	}
+}
@@ -0,0 +1,133 @@
+package rotel
+
+import (
+	"context"
+	"fmt"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// CDPlayer is a Rotel CD player (CD14, RCD series) which uses the same
+// RS232 protocol as the amplifiers
+type CDPlayer struct {
+	cdstate
+	*transport
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	REPEAT_MODES = []string{
+		"off", "track", "disc",
+	}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewCDPlayerWithConfig(cfg Config) (*CDPlayer, error) {
+	self := new(CDPlayer)
+
+	// Open the transport
+	if transport, err := newTransport(cfg); err != nil {
+		return nil, err
+	} else {
+		self.transport = transport
+	}
+
+	// Return success
+	return self, nil
+}
+
+func (self *CDPlayer) Run(ctx context.Context, ch chan<- Event) error {
+	return self.transport.run(ctx, &self.cdstate, ch)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (self *CDPlayer) SetPower(state bool) error {
+	return self.send(protocol.SetPower(state))
+}
+
+func (self *CDPlayer) Play() error {
+	return self.command("Play", protocol.Button("play"))
+}
+
+func (self *CDPlayer) Stop() error {
+	return self.command("Stop", protocol.Button("stop"))
+}
+
+func (self *CDPlayer) Pause() error {
+	return self.command("Pause", protocol.Button("pause"))
+}
+
+func (self *CDPlayer) Eject() error {
+	return self.command("Eject", protocol.Button("eject"))
+}
+
+func (self *CDPlayer) NextTrack() error {
+	return self.command("NextTrack", protocol.Button("trkf"))
+}
+
+func (self *CDPlayer) PrevTrack() error {
+	return self.command("PrevTrack", protocol.Button("trkb"))
+}
+
+func (self *CDPlayer) SetRepeat(value string) error {
+	// Cannot set value when power is off
+	if !self.Power() {
+		return ErrPowerOff.With("SetRepeat")
+	}
+
+	// Check parameter and send command
+	for _, mode := range REPEAT_MODES {
+		if mode == value {
+			return self.send(protocol.SetRepeat(value))
+		}
+	}
+	return ErrBadParameter.Withf("invalid repeat mode: %q", value)
+}
+
+func (self *CDPlayer) SetRandom(state bool) error {
+	// Cannot set value when power is off
+	if !self.Power() {
+		return ErrPowerOff.With("SetRandom")
+	}
+
+	// Send command
+	return self.send(protocol.SetRandom(state))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (self *CDPlayer) String() string {
+	str := "<cdplayer"
+	if self.transport != nil && self.fd != nil {
+		str += fmt.Sprintf(" tty=%q", self.fd)
+	}
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// command sends a transport command when the power is on
+func (self *CDPlayer) command(name string, cmd protocol.Command) error {
+	// Cannot perform action when power is off
+	if !self.Power() {
+		return ErrPowerOff.With(name)
+	}
+
+	// Send command
+	return self.send(cmd)
+}
@@ -0,0 +1,150 @@
+package rotel
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// CDState is a snapshot of the CD player state
+type CDState struct {
+	Model  string        `json:"model"`
+	Power  bool          `json:"power"`
+	Status string        `json:"status,omitempty"` // play, stop or pause
+	Disc   string        `json:"disc,omitempty"`
+	Track  uint          `json:"track,omitempty"`
+	Time   time.Duration `json:"time,omitempty"` // Elapsed time of the track
+	Repeat string        `json:"repeat,omitempty"`
+	Random bool          `json:"random,omitempty"`
+}
+
+type cdstate struct {
+	mu     sync.RWMutex
+	model  string
+	power  string
+	update string // rs232 update
+	status string // play, stop or pause
+	disc   string
+	track  string
+	time   string
+	repeat string
+	random string
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PROPERTIES
+
+func (this *cdstate) Model() string {
+	return this.State().Model
+}
+
+func (this *cdstate) Power() bool {
+	return this.State().Power
+}
+
+// Status returns play, stop or pause
+func (this *cdstate) Status() string {
+	return this.State().Status
+}
+
+// Disc returns the disc status, for example no_disc, open or loaded
+func (this *cdstate) Disc() string {
+	return this.State().Disc
+}
+
+func (this *cdstate) Track() uint {
+	return this.State().Track
+}
+
+// Time returns the elapsed time of the current track
+func (this *cdstate) Time() time.Duration {
+	return this.State().Time
+}
+
+// Repeat returns off, track or disc
+func (this *cdstate) Repeat() string {
+	return this.State().Repeat
+}
+
+func (this *cdstate) Random() bool {
+	return this.State().Random
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// State returns a consistent snapshot of the CD player state. Values other
+// than the model and power are empty when the power is off
+func (this *cdstate) State() CDState {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	s := CDState{Model: this.model, Power: this.power == "on"}
+	if !s.Power {
+		return s
+	}
+	s.Status, s.Disc, s.Repeat = this.status, this.disc, this.repeat
+	s.Random = this.random == "on"
+	if track, err := strconv.ParseUint(this.track, 0, 32); err == nil {
+		s.Track = uint(track)
+	}
+	var min, sec uint
+	if _, err := fmt.Sscanf(this.time, "%d:%d", &min, &sec); err == nil {
+		s.Time = time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+	}
+	return s
+}
+
+// Update returns a query to get state of an unknown value
+func (this *cdstate) Update(force bool) string {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	switch {
+	case this.model == "":
+		return "model?"
+	case this.power == "" || force:
+		return "power?"
+	case this.power != "on": // When power is off, don't read other values
+		return ""
+	case this.update == "":
+		return "rs232_update_on!"
+	case this.status == "":
+		return "status?"
+	case this.disc == "":
+		return "disc?"
+	case this.track == "":
+		return "track?"
+	case this.time == "":
+		return "time?"
+	case this.repeat == "":
+		return "repeat?"
+	case this.random == "":
+		return "random?"
+	}
+
+	// By default, no state needs read
+	return ""
+}
+
+// Set sets state from data coming from the CD player
+func (this *cdstate) Set(param string) (Flag, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// Decode the response
+	response, err := protocol.CD.Decode(param)
+	if err != nil {
+		return 0, err
+	}
+
+	// Set the state
+	return this.set(response)
+}
@@ -0,0 +1,139 @@
+package rotel
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_CD_001(t *testing.T) {
+	// Unknown values are queried in order, and only when the power is on
+	responses := map[string]string{
+		"model?": "model=CD14", "power?": "power=on", "rs232_update_on!": "update_mode=auto",
+		"status?": "status=stop", "disc?": "disc=loaded", "track?": "track=1", "time?": "time=0:00",
+		"repeat?": "repeat=off", "random?": "random=off",
+	}
+	for _, test := range []struct {
+		power   string
+		queries []string
+	}{
+		{"power=on", []string{"model?", "power?", "rs232_update_on!", "status?", "disc?", "track?", "time?", "repeat?", "random?"}},
+		{"power=standby", []string{"model?", "power?"}},
+	} {
+		this := new(cdstate)
+		responses["power?"] = test.power
+		var queries []string
+		for query := this.Update(false); query != ""; query = this.Update(false) {
+			if len(queries) > len(responses) {
+				t.Fatal("unexpected queries", queries)
+			}
+			queries = append(queries, query)
+			if _, err := this.Set(responses[query]); err != nil {
+				t.Fatal(query, err)
+			}
+		}
+		if len(queries) != len(test.queries) {
+			t.Errorf("%s: expected %v, got %v", test.power, test.queries, queries)
+			continue
+		}
+		for i := range queries {
+			if queries[i] != test.queries[i] {
+				t.Errorf("%s: expected %v, got %v", test.power, test.queries, queries)
+				break
+			}
+		}
+		if query := this.Update(true); query != "power?" {
+			t.Errorf("%s: expected power?, got %q", test.power, query)
+		}
+	}
+}
+
+func Test_CD_002(t *testing.T) {
+	// Responses are decoded into the state, and the elapsed time is
+	// returned as a duration
+	this := new(cdstate)
+	for _, test := range []struct {
+		param string
+		flag  Flag
+	}{
+		{"model=CD14", ROTEL_FLAG_MODEL},
+		{"power=on", ROTEL_FLAG_POWER},
+		{"update_mode=auto", ROTEL_FLAG_NONE},
+		{"status=play", ROTEL_FLAG_PLAY},
+		{"disc=loaded", ROTEL_FLAG_DISC},
+		{"track=12", ROTEL_FLAG_TRACK},
+		{"time=3:07", ROTEL_FLAG_TIME},
+		{"repeat=track", ROTEL_FLAG_REPEAT},
+		{"random=on", ROTEL_FLAG_RANDOM},
+		{"random=on", ROTEL_FLAG_NONE},
+	} {
+		if flag, err := this.Set(test.param); err != nil {
+			t.Fatal(test.param, err)
+		} else if flag != test.flag {
+			t.Errorf("%s: expected %v, got %v", test.param, test.flag, flag)
+		}
+	}
+	expected := CDState{Model: "CD14", Power: true, Status: "play", Disc: "loaded", Track: 12, Time: 3*time.Minute + 7*time.Second, Repeat: "track", Random: true}
+	if s := this.State(); s != expected {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+
+	// Invalid responses and responses from other devices are rejected
+	for _, param := range []string{"time=3:7", "status=rewind", "track=one", "volume=20"} {
+		if _, err := this.Set(param); err == nil {
+			t.Errorf("%s: expected an error", param)
+		}
+	}
+
+	// Values other than the model and power are cleared in standby
+	this.Set("power=standby")
+	if s := this.State(); s != (CDState{Model: "CD14"}) {
+		t.Errorf("unexpected state %+v", s)
+	}
+}
+
+func Test_CD_003(t *testing.T) {
+	// Transport commands and settings are rejected when the power is off,
+	// and sent when the power is on
+	conn := new(recorder)
+	transport, err := newTransportWith(Config{}, func() (io.ReadWriteCloser, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := &CDPlayer{transport: transport}
+	self.cdstate.Set("model=CD14")
+	self.cdstate.Set("power=standby")
+	for name, fn := range map[string]func() error{
+		"Play":      self.Play,
+		"Stop":      self.Stop,
+		"SetRepeat": func() error { return self.SetRepeat("disc") },
+		"SetRandom": func() error { return self.SetRandom(true) },
+	} {
+		if err := fn(); !errors.Is(err, ErrPowerOff) {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	if conn.Len() != 0 {
+		t.Errorf("unexpected commands %q", conn.String())
+	}
+
+	self.cdstate.Set("power=on")
+	if err := self.Play(); err != nil {
+		t.Error(err)
+	}
+	if err := self.SetRepeat("disc"); err != nil {
+		t.Error(err)
+	}
+	if err := self.SetRepeat("all"); err == nil {
+		t.Error("expected an error")
+	}
+	if cmd := conn.String(); cmd != "play!repeat_disc!" {
+		t.Errorf("unexpected commands %q", cmd)
+	}
+}
@@ -0,0 +1,200 @@
+package rotel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+// Test_Conformance_001 replays the transcripts in testdata/<model>/*.txt.
+// Each line of a transcript is one of:
+//
+//	# comment
+//	> query!           the driver sends the command or query next
+//	< key=value$       data received from the device, which may contain
+//	                   several responses or part of one
+//	= FLAG|FLAG        the flags of the event for the last data received
+//	error              the last data received returned an error
+//	state {...}        the state snapshot has the JSON fields given
+//
+// Data which changes the state must be followed by the flags expected,
+// and the model reported must match the directory name. Directories for
+// CD players start with "cd", and the others are for amplifiers
+func Test_Conformance_001(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) == 0 {
+		t.Fatal("no transcripts")
+	}
+	for _, file := range files {
+		file := file
+		model := filepath.Base(filepath.Dir(file))
+		t.Run(model+"/"+strings.TrimSuffix(filepath.Base(file), ".txt"), func(t *testing.T) {
+			replay(t, model, file)
+		})
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// replay runs a transcript against the state, and the transport reading
+// and framing responses
+func replay(t *testing.T, model, file string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fh, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	// Create the device, and a transport which reads data received from
+	// a connection
+	dev := newReplayed(model)
+	conn := new(recorder)
+	self, err := newTransportWith(Config{}, func() (io.ReadWriteCloser, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := self.Subscribe(ctx, ROTEL_FLAG_NONE)
+
+	// Flags and error for the last data received, not yet checked
+	var flags Flag
+	var result error
+
+	scanner := bufio.NewScanner(fh)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		directive := strings.SplitN(text, " ", 2)
+		arg := ""
+		if len(directive) > 1 {
+			arg = strings.TrimSpace(directive[1])
+		}
+
+		// Any error must be checked by the next line
+		if result != nil && directive[0] != "error" {
+			t.Fatalf("%s:%d: unexpected error: %v", file, line, result)
+		}
+
+		switch directive[0] {
+		case ">":
+			if cmd := dev.Update(false); cmd != arg {
+				t.Fatalf("%s:%d: expected %q, driver sent %q", file, line, arg, cmd)
+			}
+		case "<":
+			if flags != ROTEL_FLAG_NONE {
+				t.Fatalf("%s:%d: unexpected flags %v", file, line, flags)
+			}
+			conn.WriteString(arg)
+			result = self.readtty(dev)
+			flags = drain(events)
+		case "=":
+			if expected, err := ParseFlag(arg); err != nil {
+				t.Fatalf("%s:%d: %v", file, line, err)
+			} else if flags != expected {
+				t.Fatalf("%s:%d: expected flags %v, got %v", file, line, expected, flags)
+			}
+			flags = ROTEL_FLAG_NONE
+		case "error":
+			if result == nil {
+				t.Fatalf("%s:%d: expected an error", file, line)
+			}
+			result = nil
+		case "state":
+			if err := compareState(stateOf(dev), arg); err != "" {
+				t.Fatalf("%s:%d: %s", file, line, err)
+			}
+		default:
+			t.Fatalf("%s:%d: unexpected line: %q", file, line, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the end of the transcript
+	if result != nil {
+		t.Fatalf("%s: unexpected error: %v", file, result)
+	} else if flags != ROTEL_FLAG_NONE {
+		t.Fatalf("%s: unexpected flags %v", file, flags)
+	} else if actual := replayedModel(dev); actual != model {
+		t.Fatalf("%s: model %q does not match directory %q", file, actual, model)
+	}
+}
+
+// newReplayed returns the device for a transcript directory
+func newReplayed(model string) device {
+	if strings.HasPrefix(model, "cd") {
+		return new(cdstate)
+	}
+	return new(state)
+}
+
+// replayedModel returns the model of the device as a directory name
+func replayedModel(dev device) string {
+	switch dev := dev.(type) {
+	case *state:
+		return dev.Profile().Model
+	case *cdstate:
+		return normaliseModel(dev.Model())
+	default:
+		return ""
+	}
+}
+
+// drain returns the flags of all events waiting on the channel
+func drain(events <-chan Event) Flag {
+	var flags Flag
+	for {
+		select {
+		case evt := <-events:
+			flags |= evt.Flag
+		default:
+			return flags
+		}
+	}
+}
+
+// compareState returns a description of any field in the JSON expected
+// which does not match the state
+func compareState(state Snapshot, expected string) string {
+	var want, got map[string]interface{}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		return err.Error()
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err.Error()
+	} else if err := json.Unmarshal(data, &got); err != nil {
+		return err.Error()
+	}
+	var result []string
+	for key, value := range want {
+		if actual, exists := got[key]; !exists {
+			result = append(result, "unknown field "+key)
+		} else if actual != value {
+			data, _ := json.Marshal(actual)
+			result = append(result, key+"="+string(data))
+		}
+	}
+	if len(result) > 0 {
+		return "unexpected state: " + strings.Join(result, ", ")
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package rotel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// deferral queues commands sent while the amplifier is powering on, which
+// are applied once the power is on and the amplifier has warmed up
+type deferral struct {
+	mu       sync.Mutex
+	apply    sync.Mutex    // Held while commands are sent, to keep their order
+	timeout  time.Duration // Time to keep a command, or zero to disable
+	powering time.Time     // Time power on was requested
+	queue    []deferred
+}
+
+// deferred is a queued command, where fn sends the command
+type deferred struct {
+	name string
+	fn   func() error
+	at   time.Time
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newDeferral(timeout time.Duration) *deferral {
+	return &deferral{
+		timeout: timeout,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// ready sends a command by calling fn when the power is on. When the
+// amplifier is powering on, the command is queued and nil is returned.
+// Otherwise, ErrPowerOff is returned. The power is the power confirmed by
+// the amplifier, not any provisional value
+func (self *Rotel) ready(name string, fn func() error) error {
+	d := self.deferral
+	now := time.Now()
+
+	// When disabled, commands are sent when power is on
+	if d.timeout == 0 {
+		if self.Power() {
+			return fn()
+		}
+		return ErrPowerOff.With(name)
+	}
+
+	d.mu.Lock()
+	switch {
+	case len(d.queue) == 0 && d.warm(now, self.state.warm(now)):
+		d.mu.Unlock()
+
+		// Send after any queued commands being sent
+		d.apply.Lock()
+		defer d.apply.Unlock()
+		return fn()
+	case self.Power() || now.Sub(d.powering) < d.timeout:
+		// Queue commands after any already queued, to keep the order
+		d.queue = append(d.queue, deferred{name, fn, now})
+		d.mu.Unlock()
+		return nil
+	default:
+		d.mu.Unlock()
+		return ErrPowerOff.With(name)
+	}
+}
+
+// flush sends queued commands in order once the amplifier is ready, and
+// drops commands which have been queued for longer than the timeout. A
+// command which fails is not queued again
+func (self *Rotel) flush(ctx context.Context) {
+	ticker := time.NewTicker(deltaUpdate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			self.flushQueue(time.Now())
+		}
+	}
+}
+
+// flushQueue sends the queued commands when warm, and reports those
+// which have expired
+func (self *Rotel) flushQueue(now time.Time) {
+	d := self.deferral
+	d.apply.Lock()
+	defer d.apply.Unlock()
+	ready, expired := d.take(now, self.state.warm(now))
+	for _, cmd := range expired {
+		self.publish(newError(ErrTimeout.Withf("%s: deferred until power on", cmd.name)))
+	}
+	for _, cmd := range ready {
+		if err := cmd.fn(); err != nil {
+			self.publish(newError(err))
+		}
+	}
+}
+
+// power records a power on or off request. Queued commands are dropped
+// when the power is switched off
+func (d *deferral) power(state bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if state {
+		d.powering = time.Now()
+	} else {
+		d.powering = time.Time{}
+		for i := range d.queue {
+			d.queue[i].at = time.Time{}
+		}
+	}
+}
+
+// take returns the queued commands to apply when warm, and those which
+// have expired, and removes them from the queue
+func (d *deferral) take(now time.Time, warm bool) ([]deferred, []deferred) {
+	var ready, expired, queue []deferred
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	warm = d.warm(now, warm)
+	for _, cmd := range d.queue {
+		switch {
+		case now.Sub(cmd.at) > d.timeout:
+			expired = append(expired, cmd)
+		case warm:
+			ready = append(ready, cmd)
+		default:
+			queue = append(queue, cmd)
+		}
+	}
+	d.queue = queue
+	return ready, expired
+}
+
+// warm returns true if the amplifier is warm, and has warmed up since any
+// power on was requested. It should be called with the lock held
+func (d *deferral) warm(now time.Time, warm bool) bool {
+	return warm && now.Sub(d.powering) > deltaWarmup
+}
+
+// warm returns true if the power is on and the amplifier has warmed up
+// since it was switched on
+func (this *state) warm(now time.Time) bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.power == "on" && now.Sub(this.powered) > deltaWarmup
+}
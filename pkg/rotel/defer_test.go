@@ -0,0 +1,198 @@
+package rotel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	// Namespace imports
+	goerrors "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Defer_001(t *testing.T) {
+	// Commands sent while powering on are queued, and sent in order once
+	// the amplifier has warmed up
+	self, conn := newDeferred(t, "model=A14", "power=standby")
+	if err := self.SetPower(true); err != nil {
+		t.Fatal(err)
+	}
+	conn.Reset()
+	if err := self.SetVolume(30); err != nil {
+		t.Fatal(err)
+	}
+	if err := self.SetSource("cd"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not sent until warmed up
+	self.state.Set("power=on")
+	self.flushQueue(time.Now())
+	if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+
+	// Commands are queued behind queued commands once warm
+	if err := self.SetBass(1); err != nil {
+		t.Fatal(err)
+	}
+	self.flushQueue(time.Now().Add(2 * deltaWarmup))
+	if cmd := conn.String(); cmd != "vol_30!cd!bass_+1!" {
+		t.Errorf("unexpected commands %q", cmd)
+	}
+
+	// Sent directly when nothing is queued
+	conn.Reset()
+	self.state.mu.Lock()
+	self.state.powered = time.Now().Add(-2 * deltaWarmup)
+	self.state.mu.Unlock()
+	self.deferral.mu.Lock()
+	self.deferral.powering = time.Now().Add(-2 * deltaWarmup)
+	self.deferral.mu.Unlock()
+	if err := self.SetTreble(-1); err != nil {
+		t.Fatal(err)
+	} else if cmd := conn.String(); cmd != "treble_-1!" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+}
+
+func Test_Defer_002(t *testing.T) {
+	// Invalid parameters are rejected rather than queued, and a queued
+	// command which fails is reported and not queued again, so later
+	// commands keep their order
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	self, conn := newDeferred(t, "model=A14", "power=standby", "power=on")
+	ch := self.Subscribe(ctx, ROTEL_FLAG_NONE)
+	if err := self.SetSource("dolby"); !errors.Is(err, goerrors.ErrBadParameter) {
+		t.Error("unexpected error:", err)
+	}
+	if err := self.SetVolume(500); !errors.Is(err, ErrOutOfRange) {
+		t.Error("unexpected error:", err)
+	}
+	for _, fn := range []func() error{
+		func() error { return self.ready("Fail", func() error { return goerrors.ErrInternalAppError }) },
+		func() error { return self.SetVolume(30) },
+	} {
+		if err := fn(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	self.flushQueue(time.Now().Add(2 * deltaWarmup))
+	if cmd := conn.String(); cmd != "vol_30!" {
+		t.Errorf("unexpected commands %q", cmd)
+	}
+	if evt := <-ch; !errors.Is(evt.Err, goerrors.ErrInternalAppError) {
+		t.Error("unexpected event", evt)
+	}
+	if len(self.deferral.queue) != 0 {
+		t.Error("unexpected queued commands", len(self.deferral.queue))
+	}
+}
+
+func Test_Defer_003(t *testing.T) {
+	// Queued commands expire after the timeout, or when the power is
+	// switched off
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	self, conn := newDeferred(t, "model=A14", "power=standby")
+	ch := self.Subscribe(ctx, ROTEL_FLAG_NONE)
+	self.SetPower(true)
+	conn.Reset()
+	if err := self.SetVolume(30); err != nil {
+		t.Fatal(err)
+	}
+	self.flushQueue(time.Now().Add(2 * self.deferral.timeout))
+	if evt := <-ch; !errors.Is(evt.Err, ErrTimeout) {
+		t.Error("unexpected event", evt)
+	}
+
+	self.SetPower(true)
+	if err := self.SetVolume(30); err != nil {
+		t.Fatal(err)
+	}
+	self.SetPower(false)
+	conn.Reset()
+	self.flushQueue(time.Now())
+	if evt := <-ch; !errors.Is(evt.Err, ErrTimeout) {
+		t.Error("unexpected event", evt)
+	}
+	if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+
+	// Commands are rejected when not powering on
+	if err := self.SetVolume(30); !errors.Is(err, ErrPowerOff) {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func Test_Defer_004(t *testing.T) {
+	// Commands are only sent when the amplifier has confirmed the power
+	// is on, not when the last known power is on
+	self, conn := newRecorded(t)
+	self.state.provisional = &persisted{Model: "A14", Power: "on"}
+	if err := self.SetVolume(30); !errors.Is(err, ErrPowerOff) {
+		t.Error("unexpected error:", err)
+	}
+	self.state.Set("model=A14")
+	self.state.Set("power=on")
+	if err := self.SetVolume(30); err != nil {
+		t.Error(err)
+	} else if cmd := conn.String(); cmd != "vol_30!" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+}
+
+func Test_Defer_005(t *testing.T) {
+	// When the amplifier is already on when connected, commands are sent
+	// straight away without waiting for a warm-up
+	self, conn := newDeferred(t, "model=A14", "power=on")
+	if err := self.SetVolume(30); err != nil {
+		t.Fatal(err)
+	} else if cmd := conn.String(); cmd != "vol_30!" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+
+	// A power on sent by the driver waits for a warm-up
+	conn.Reset()
+	if err := self.SetPower(true); err != nil {
+		t.Fatal(err)
+	}
+	conn.Reset()
+	if err := self.SetSource("cd"); err != nil {
+		t.Fatal(err)
+	} else if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+	self.flushQueue(time.Now().Add(2 * deltaWarmup))
+	if cmd := conn.String(); cmd != "cd!" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// newDeferred returns a driver which queues commands while powering on,
+// with the state set from responses
+func newDeferred(t *testing.T, params ...string) (*Rotel, *recorder) {
+	t.Helper()
+	conn := new(recorder)
+	self, err := NewWithConn(Config{Defer: 10 * time.Second}, func() (io.ReadWriteCloser, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, param := range params {
+		if _, err := self.state.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	return self, conn
+}
@@ -0,0 +1,312 @@
+package emulator
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Replies for commands which are not recognised or have invalid values
+	errUnknownCommand = errors.New("unknown_command")
+	errInvalidCommand = errors.New("invalid_command")
+)
+
+var (
+	reVolume  = regexp.MustCompile("^vol_(\\d+)$")
+	reTone    = regexp.MustCompile("^(bass|treble)_(up|down|000|[\\+\\-]\\d+)$")
+	reBalance = regexp.MustCompile("^balance_(l|r|000|[lr]\\d+)$")
+	reSpeaker = regexp.MustCompile("^speaker_(a|b)(_on|_off)?$")
+	reDimmer  = regexp.MustCompile("^dimmer(_\\d+)?$")
+	rePhono   = regexp.MustCompile("^phono_(mm|mc)$")
+	reTrim    = regexp.MustCompile("^(center|subwoofer|surround)_(000|[\\+\\-]\\d+)$")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// apply applies a command or query, and returns the response. It should
+// be called with the lock held
+func (self *Emulator) apply(cmd string) (string, error) {
+	switch {
+	case strings.HasSuffix(cmd, "?"):
+		return self.query(strings.TrimSuffix(cmd, "?"))
+	case strings.HasSuffix(cmd, "!"):
+		return self.set(strings.TrimSuffix(cmd, "!"))
+	default:
+		return "", errUnknownCommand
+	}
+}
+
+// query returns the response to a query. In standby, only the power,
+// model and update mode are returned
+func (self *Emulator) query(key string) (string, error) {
+	switch key {
+	case "power", "model", "update_mode":
+		return self.response(key), nil
+	}
+	if !self.state.Power {
+		return "", nil
+	}
+	switch key {
+	case "volume", "mute", "source", "bass", "treble", "balance", "freq", "speaker", "bypass", "dimmer":
+		return self.response(key), nil
+	case "phono_mode":
+		if self.profile.Phono {
+			return self.response(key), nil
+		}
+	case "mode", "format", "center", "subwoofer", "surround":
+		if self.profile.Processor {
+			return self.response(key), nil
+		}
+	}
+	return "", errUnknownCommand
+}
+
+// set applies a command and returns the response with the changed value.
+// In standby, only power and update mode commands are accepted
+func (self *Emulator) set(cmd string) (string, error) {
+	switch cmd {
+	case "power_on":
+		self.state.Power = true
+		return self.response("power"), nil
+	case "power_off":
+		self.state.Power = false
+		return self.response("power"), nil
+	case "power_toggle":
+		self.state.Power = !self.state.Power
+		return self.response("power"), nil
+	case "rs232_update_on", "rs232_update_off":
+		self.update = cmd == "rs232_update_on"
+		return self.response("update_mode"), nil
+	}
+	if !self.state.Power {
+		return "", nil
+	}
+
+	// Commands when power is on
+	switch {
+	case cmd == "vol_up":
+		return self.setVolume(self.state.Volume + 1)
+	case cmd == "vol_down" || cmd == "vol_dwn":
+		return self.setVolume(self.state.Volume - 1)
+	case reVolume.MatchString(cmd):
+		value, _ := strconv.ParseUint(reVolume.FindStringSubmatch(cmd)[1], 10, 32)
+		return self.setVolume(uint(value))
+	case cmd == "mute" || cmd == "mute_on" || cmd == "mute_off":
+		self.state.Mute = cmd == "mute_on" || (cmd == "mute" && !self.state.Mute)
+		return self.response("mute"), nil
+	case cmd == "bypass_on" || cmd == "bypass_off":
+		self.state.Bypass = cmd == "bypass_on"
+		return self.response("bypass"), nil
+	case reTone.MatchString(cmd):
+		args := reTone.FindStringSubmatch(cmd)
+		return self.setTone(args[1], args[2])
+	case reBalance.MatchString(cmd):
+		return self.setBalance(reBalance.FindStringSubmatch(cmd)[1])
+	case reSpeaker.MatchString(cmd):
+		args := reSpeaker.FindStringSubmatch(cmd)
+		return self.setSpeaker(args[1], args[2])
+	case reDimmer.MatchString(cmd):
+		return self.setDimmer(strings.TrimPrefix(reDimmer.FindStringSubmatch(cmd)[1], "_"))
+	case rePhono.MatchString(cmd) && self.profile.Phono:
+		self.state.PhonoMode = rePhono.FindStringSubmatch(cmd)[1]
+		return self.response("phono_mode"), nil
+	case reTrim.MatchString(cmd) && self.profile.Processor:
+		args := reTrim.FindStringSubmatch(cmd)
+		return self.setTrim(args[1], args[2])
+	case isMode(cmd) && self.profile.Processor:
+		self.state.Mode = cmd
+		return self.response("mode"), nil
+	case cmd == "pcusb" && self.profile.HasSource("pc_usb"):
+		return self.setSource("pc_usb")
+	case cmd != "pc_usb" && self.profile.HasSource(cmd):
+		return self.setSource(cmd)
+	default:
+		return "", errUnknownCommand
+	}
+}
+
+func (self *Emulator) setVolume(value uint) (string, error) {
+	if value < self.profile.VolumeMin || value > self.profile.VolumeMax {
+		return "", errInvalidCommand
+	}
+	self.state.Volume = value
+	return self.response("volume"), nil
+}
+
+func (self *Emulator) setTone(key, arg string) (string, error) {
+	value := &self.state.Bass
+	if key == "treble" {
+		value = &self.state.Treble
+	}
+
+	// Determine the new value
+	next := *value
+	switch arg {
+	case "up":
+		next++
+	case "down":
+		next--
+	default:
+		v, _ := strconv.ParseInt(arg, 10, 32)
+		next = int(v)
+	}
+
+	// Check range and set
+	if next < rotel.TONE_MIN || next > rotel.TONE_MAX {
+		return "", errInvalidCommand
+	}
+	*value = next
+	return self.response(key), nil
+}
+
+func (self *Emulator) setTrim(key, arg string) (string, error) {
+	value := &self.state.Center
+	switch key {
+	case "subwoofer":
+		value = &self.state.Subwoofer
+	case "surround":
+		value = &self.state.Surround
+	}
+
+	// Check range and set
+	next, _ := strconv.ParseInt(arg, 10, 32)
+	if next < rotel.TRIM_MIN || next > rotel.TRIM_MAX {
+		return "", errInvalidCommand
+	}
+	*value = int(next)
+	return self.response(key), nil
+}
+
+func (self *Emulator) setBalance(arg string) (string, error) {
+	next := self.state.Balance
+	switch {
+	case arg == "l":
+		next--
+	case arg == "r":
+		next++
+	case arg == "000":
+		next = 0
+	default:
+		v, _ := strconv.ParseInt(arg[1:], 10, 32)
+		if next = int(v); arg[0] == 'l' {
+			next = -next
+		}
+	}
+
+	// Check range and set
+	if next < -BALANCE_MAX || next > BALANCE_MAX {
+		return "", errInvalidCommand
+	}
+	self.state.Balance = next
+	return self.response("balance"), nil
+}
+
+func (self *Emulator) setSpeaker(speaker, arg string) (string, error) {
+	value := &self.state.SpeakerA
+	if speaker == "b" {
+		value = &self.state.SpeakerB
+	}
+	switch arg {
+	case "_on":
+		*value = true
+	case "_off":
+		*value = false
+	default:
+		*value = !*value
+	}
+	return self.response("speaker"), nil
+}
+
+func (self *Emulator) setDimmer(arg string) (string, error) {
+	next := self.state.Dimmer + 1
+	if arg != "" {
+		v, _ := strconv.ParseUint(arg, 10, 32)
+		next = uint(v)
+	} else if next > DIMMER_MAX {
+		next = 0
+	}
+	if next > DIMMER_MAX {
+		return "", errInvalidCommand
+	}
+	self.state.Dimmer = next
+	return self.response("dimmer"), nil
+}
+
+func (self *Emulator) setSource(source string) (string, error) {
+	self.state.Source = source
+	switch {
+	case strings.HasPrefix(source, "coax"), strings.HasPrefix(source, "opt"), source == "usb", source == "pc_usb":
+		self.state.Freq = "44.1"
+	default:
+		self.state.Freq = "off"
+	}
+	return self.response("source"), nil
+}
+
+// isMode returns true if the command is a surround mode
+func isMode(cmd string) bool {
+	for _, mode := range rotel.MODES {
+		if cmd == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// response returns the key=value$ response for a key
+func (self *Emulator) response(key string) string {
+	var r protocol.Response
+	switch key {
+	case "model":
+		r = protocol.Model(self.state.Model)
+	case "power":
+		r = protocol.Power(self.state.Power)
+	case "update_mode":
+		r = protocol.UpdateMode(self.update)
+	case "volume":
+		r = protocol.Volume(self.state.Volume)
+	case "mute":
+		r = protocol.Mute(self.state.Mute)
+	case "bypass":
+		r = protocol.Bypass(self.state.Bypass)
+	case "source":
+		r = protocol.Source(self.state.Source)
+	case "freq":
+		r = protocol.Freq(self.state.Freq)
+	case "bass":
+		r = protocol.Bass(self.state.Bass)
+	case "treble":
+		r = protocol.Treble(self.state.Treble)
+	case "balance":
+		r = protocol.Balance(self.state.Balance)
+	case "speaker":
+		r = protocol.Speaker{A: self.state.SpeakerA, B: self.state.SpeakerB}
+	case "dimmer":
+		r = protocol.Dimmer(self.state.Dimmer)
+	case "phono_mode":
+		r = protocol.PhonoMode(self.state.PhonoMode)
+	case "mode":
+		r = protocol.Mode(self.state.Mode)
+	case "format":
+		r = protocol.Format(self.state.Format)
+	case "center":
+		r = protocol.Center(self.state.Center)
+	case "subwoofer":
+		r = protocol.Subwoofer(self.state.Subwoofer)
+	case "surround":
+		r = protocol.Surround(self.state.Surround)
+	default:
+		return ""
+	}
+	return string(r.Encode())
+}
@@ -0,0 +1,127 @@
+package emulator
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Emulator_001(t *testing.T) {
+	// Values are changed within their range, and values out of range are
+	// rejected without a change
+	on := rotel.State{Power: true, Volume: 95, Bass: 9, Treble: -9, Balance: 14, Dimmer: 5, Source: "cd"}
+	tests := []struct {
+		model    string
+		state    rotel.State
+		cmds     []string
+		response string
+		err      error
+	}{
+		{"A14", on, []string{"bass_up!"}, "bass=+10$", nil},
+		{"A14", on, []string{"bass_up!", "bass_up!"}, "", errInvalidCommand},
+		{"A14", on, []string{"bass_-10!"}, "bass=-10$", nil},
+		{"A14", on, []string{"bass_-11!"}, "", errInvalidCommand},
+		{"A14", on, []string{"treble_down!"}, "treble=-10$", nil},
+		{"A14", on, []string{"treble_down!", "treble_down!"}, "", errInvalidCommand},
+		{"A14", on, []string{"treble_+11!"}, "", errInvalidCommand},
+		{"A14", on, []string{"balance_r!"}, "balance=R15$", nil},
+		{"A14", on, []string{"balance_r!", "balance_r!"}, "", errInvalidCommand},
+		{"A14", on, []string{"balance_l15!"}, "balance=L15$", nil},
+		{"A14", on, []string{"balance_l16!"}, "", errInvalidCommand},
+		{"A14", on, []string{"dimmer!"}, "dimmer=6$", nil},
+		{"A14", on, []string{"dimmer!", "dimmer!"}, "dimmer=0$", nil},
+		{"A14", on, []string{"dimmer_0!"}, "dimmer=0$", nil},
+		{"A14", on, []string{"dimmer_7!"}, "", errInvalidCommand},
+		{"A14", on, []string{"vol_up!"}, "volume=96$", nil},
+		{"A14", on, []string{"vol_up!", "vol_up!"}, "", errInvalidCommand},
+		{"A14", on, []string{"vol_97!"}, "", errInvalidCommand},
+		{"A14", on, []string{"vol_0!"}, "", errInvalidCommand},
+		{"Michi P5", on, []string{"vol_up!", "vol_up!"}, "volume=97$", nil},
+		{"Michi P5", on, []string{"vol_120!"}, "volume=120$", nil},
+		{"Michi P5", on, []string{"vol_121!"}, "", errInvalidCommand},
+	}
+	for _, test := range tests {
+		self := New(nil, Config{Model: test.model, State: test.state})
+		var response string
+		var err error
+		for _, cmd := range test.cmds {
+			response, err = self.apply(cmd)
+		}
+		if err != test.err {
+			t.Errorf("%s %q: expected error %v, got %v", test.model, test.cmds, test.err, err)
+		} else if response != test.response {
+			t.Errorf("%s %q: expected %q, got %q", test.model, test.cmds, test.response, response)
+		}
+	}
+}
+
+func Test_Emulator_002(t *testing.T) {
+	// In standby, only the power, model and update mode are answered, and
+	// other commands are ignored
+	self := New(nil, Config{Model: "A14"})
+	state := self.State()
+	for cmd, expected := range map[string]string{
+		"power?":       "power=standby$",
+		"model?":       "model=A14$",
+		"update_mode?": "update_mode=manual$",
+		"volume?":      "",
+		"source?":      "",
+		"vol_30!":      "",
+		"cd!":          "",
+		"dimmer_3!":    "",
+	} {
+		if response, err := self.apply(cmd); err != nil {
+			t.Error(cmd, err)
+		} else if response != expected {
+			t.Errorf("%q: expected %q, got %q", cmd, expected, response)
+		}
+	}
+	if self.State() != state {
+		t.Error("unexpected change in standby", self.State())
+	}
+
+	// Once on, values are answered
+	for _, cmd := range [][2]string{
+		{"power_on!", "power=on$"},
+		{"vol_30!", "volume=30$"},
+		{"volume?", "volume=30$"},
+	} {
+		if response, err := self.apply(cmd[0]); err != nil {
+			t.Error(cmd[0], err)
+		} else if response != cmd[1] {
+			t.Errorf("%q: expected %q, got %q", cmd[0], cmd[1], response)
+		}
+	}
+}
+
+func Test_Emulator_003(t *testing.T) {
+	// The state can be read and changed while a response is delayed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, server := net.Pipe()
+	defer client.Close()
+	self := New(server, Config{Model: "A14", State: rotel.State{Power: true, Volume: 20}, Latency: time.Second})
+	go self.Run(ctx)
+
+	if _, err := client.Write([]byte("volume?")); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		self.State()
+		self.FrontPanel("vol_up")
+	}()
+	select {
+	case <-done:
+	case <-time.After(self.latency / 2):
+		t.Fatal("state locked while the response is delayed")
+	}
+}
@@ -0,0 +1,200 @@
+// Package emulator implements a software Rotel amplifier, which answers the
+// A12/A14 RS232 command set, and the listening modes and channel trims of
+// surround processors, over any io.ReadWriteCloser, for example one end
+// of a net.Pipe or a pseudo-terminal
+package emulator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Config struct {
+	Model   string        // Model to emulate, defaults to A14
+	State   rotel.State   // Initial state, or defaults when empty
+	Latency time.Duration // Delay before each response
+	Update  bool          // Send updates for front panel changes, as after rs232_update_on!
+}
+
+// Emulator is a software amplifier
+type Emulator struct {
+	mu      sync.Mutex // Guards the state
+	wr      sync.Mutex // Keeps responses in order
+	conn    io.ReadWriteCloser
+	profile rotel.Profile
+	latency time.Duration
+	state   rotel.State
+	update  bool // Send updates for front panel changes
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	DEFAULT_MODEL = "A14"
+	DIMMER_MAX    = 6
+	BALANCE_MAX   = 15
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// New returns an emulator which reads commands from and writes responses to
+// the connection
+func New(conn io.ReadWriteCloser, cfg Config) *Emulator {
+	self := new(Emulator)
+	self.conn = conn
+	self.latency = cfg.Latency
+	self.update = cfg.Update
+
+	// Set model
+	if cfg.Model == "" {
+		cfg.Model = DEFAULT_MODEL
+	}
+	self.profile = rotel.ProfileForModel(cfg.Model)
+
+	// Set initial state
+	if cfg.State == (rotel.State{}) {
+		self.state = DefaultState()
+	} else {
+		self.state = cfg.State
+	}
+	self.state.Model = cfg.Model
+	if self.profile.Processor && self.state.Mode == "" {
+		self.state.Mode, self.state.Format = "stereo", "pcm"
+	}
+
+	// Return the emulator
+	return self
+}
+
+// DefaultState returns the state of an emulator in standby
+func DefaultState() rotel.State {
+	return rotel.State{
+		Volume:    20,
+		Source:    "cd",
+		Freq:      "off",
+		SpeakerA:  true,
+		PhonoMode: "mm",
+	}
+}
+
+// Run reads and answers commands until the context is cancelled or the
+// connection is closed, then closes the connection
+func (self *Emulator) Run(ctx context.Context) error {
+	var result error
+
+	// Close the connection when the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		self.conn.Close()
+	}()
+
+	// Read commands, which end with ! or ?
+	var buf strings.Builder
+	data := make([]byte, 256)
+	for {
+		n, err := self.conn.Read(data)
+		for _, ch := range data[:n] {
+			buf.WriteByte(ch)
+			if ch == '!' || ch == '?' {
+				self.command(buf.String())
+				buf.Reset()
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) && ctx.Err() == nil {
+				result = err
+			}
+			break
+		}
+	}
+
+	// Return any errors
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// State returns the current state of the emulator
+func (self *Emulator) State() rotel.State {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.state
+}
+
+// Update returns true when updates are sent for front panel changes
+func (self *Emulator) Update() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.update
+}
+
+// FrontPanel applies a command as if from the front panel or remote
+// control, for example "vol_up!" or "power_off!". Changes are sent to the
+// connection when updates are enabled with rs232_update_on!
+func (self *Emulator) FrontPanel(cmd string) error {
+	self.mu.Lock()
+
+	if !strings.HasSuffix(cmd, "!") {
+		cmd += "!"
+	}
+	response, err := self.apply(cmd)
+	if err != nil {
+		self.mu.Unlock()
+		return err
+	} else if !self.update {
+		response = ""
+	}
+	return self.write(response)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// command answers a command or query from the connection
+func (self *Emulator) command(cmd string) {
+	self.mu.Lock()
+
+	response, err := self.apply(cmd)
+	if err != nil {
+		response = string(protocol.DeviceError(err.Error()).Encode())
+	}
+	self.write(response)
+}
+
+// write sends any response after the latency. It should be called with
+// the lock held, and releases the lock before waiting so that the state
+// can be read and changed in the meantime, while keeping responses in order
+func (self *Emulator) write(response string) error {
+	if response == "" {
+		self.mu.Unlock()
+		return nil
+	}
+
+	self.wr.Lock()
+	defer self.wr.Unlock()
+	self.mu.Unlock()
+	if self.latency > 0 {
+		time.Sleep(self.latency)
+	}
+	_, err := self.conn.Write([]byte(response))
+	return err
+}
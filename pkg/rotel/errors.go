@@ -0,0 +1,111 @@
+package rotel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	// Modules
+	errors "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Err is an error returned by the driver, which can be compared with
+// errors.Is. Each value also matches the equivalent go-errors value, so
+// existing callers continue to work
+type Err uint
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ErrPowerOff     Err = iota + 1 // Command not accepted while power is off
+	ErrOutOfRange                  // Value is outside the range for the model
+	ErrUnsupported                 // Not supported by the model
+	ErrTimeout                     // Device did not respond to a query
+	ErrFraming                     // Response could not be framed
+	ErrDevice                      // Device reported an error
+	ErrConflict                    // Desired value was changed elsewhere
+	ErrDisconnected                // Connection to the device has failed
+)
+
+var (
+	// Replies from a device which report an error or unknown command
+	reDeviceError = regexp.MustCompile("^(?:error=(.*)|(unknown_command|invalid_command))$")
+
+	// A well-formed response is a key, an equals sign and a value without
+	// control characters. Station text can contain bytes above 0x7F
+	reResponse = regexp.MustCompile("^\\w+=[^\\x00-\\x1F\\x7F]*$")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e Err) Error() string {
+	switch e {
+	case ErrPowerOff:
+		return "ErrPowerOff"
+	case ErrOutOfRange:
+		return "ErrOutOfRange"
+	case ErrUnsupported:
+		return "ErrUnsupported"
+	case ErrTimeout:
+		return "ErrTimeout"
+	case ErrFraming:
+		return "ErrFraming"
+	case ErrDevice:
+		return "ErrDevice"
+	case ErrConflict:
+		return "ErrConflict"
+	case ErrDisconnected:
+		return "ErrDisconnected"
+	default:
+		return "[?? Invalid Err value]"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (e Err) With(args ...interface{}) error {
+	return fmt.Errorf("%w: %s", e, fmt.Sprint(args...))
+}
+
+func (e Err) Withf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", e, fmt.Sprintf(format, args...))
+}
+
+// Is returns true if the target is the equivalent go-errors value
+func (e Err) Is(target error) bool {
+	switch e {
+	case ErrPowerOff, ErrDisconnected:
+		return target == errors.ErrOutOfOrder
+	case ErrOutOfRange:
+		return target == errors.ErrBadParameter
+	case ErrUnsupported:
+		return target == errors.ErrNotImplemented
+	case ErrFraming, ErrDevice:
+		return target == errors.ErrUnexpectedResponse
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// responseError returns an error if the response is an error reply from
+// the device or is not well-formed, or nil otherwise
+func responseError(param string) error {
+	if args := reDeviceError.FindStringSubmatch(param); len(args) != 0 {
+		if args[1] != "" {
+			return ErrDevice.With(args[1])
+		} else {
+			return ErrDevice.With(args[2])
+		}
+	} else if !reResponse.MatchString(param) {
+		return ErrFraming.With(strconv.Quote(param))
+	}
+	return nil
+}
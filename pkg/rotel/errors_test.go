@@ -0,0 +1,38 @@
+package rotel
+
+import (
+	"errors"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Errors_001(t *testing.T) {
+	// Error replies and malformed responses are recognised
+	tests := []struct {
+		param string
+		err   error
+	}{
+		{"volume=20", nil},
+		{"format=PCM 2.0", nil},
+		{"rds=", nil},
+		{"rds=Caf\xe9 FM", nil},
+		{"dls=001,Radio 3 \xc2\xb7 Live", nil},
+		{"error=invalid value", ErrDevice},
+		{"unknown_command", ErrDevice},
+		{"invalid_command", ErrDevice},
+		{"volume", ErrFraming},
+		{"=20", ErrFraming},
+		{"volume=2\x000", ErrFraming},
+		{"rds=\x1b[1m", ErrFraming},
+		{"rds=a\x7fb", ErrFraming},
+	}
+	for _, test := range tests {
+		if err := responseError(test.param); test.err == nil && err != nil {
+			t.Errorf("%q: unexpected error: %v", test.param, err)
+		} else if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected %v, got %v", test.param, test.err, err)
+		}
+	}
+}
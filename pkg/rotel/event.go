@@ -1,9 +1,31 @@
 package rotel
 
+import (
+	"time"
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 // TYPES
 
+// Event is emitted when state changes or an error occurs. Old and New are
+// the state before and after the change, so the exact transition of each
+// field in Flag can be read from them. They are a State for amplifiers, a
+// CDState for CD players and a TunerState for tuners, or nil for errors
 type Event struct {
 	Flag
-	Err error
+	Err  error
+	Time time.Time // Time of the change or error
+	Old  Snapshot  // Device state before the change
+	New  Snapshot  // Device state after the change
+
+	// Desired fields not yet applied, for ROTEL_FLAG_DESIRED events
+	Pending Flag
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// newError returns an event for an error
+func newError(err error) Event {
+	return Event{Err: err, Time: time.Now()}
 }
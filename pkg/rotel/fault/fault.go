@@ -0,0 +1,302 @@
+// Package fault wraps a connection to a device and injects faults into the
+// responses read from it, for testing how a driver behaves under bad
+// conditions. Faults are applied to each response ending in "$", either
+// from a script or at random
+package fault
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Fault is a fault applied to a response
+type Fault uint
+
+type Config struct {
+	Seed    int64         // Seed for random faults
+	Rate    float64       // Probability of a random fault for each response, from 0 to 1
+	Faults  []Fault       // Faults chosen at random, or all except FAULT_EOF and FAULT_DISCONNECT when empty
+	Latency time.Duration // Delay for FAULT_LATENCY, defaults to 100ms
+}
+
+// Conn is a connection which injects faults
+type Conn struct {
+	mu      sync.Mutex
+	conn    io.ReadWriteCloser
+	rand    *rand.Rand
+	rate    float64
+	faults  []Fault
+	latency time.Duration
+	script  []Fault       // Faults for the next responses
+	in      []byte        // Data read which is not yet a complete response
+	out     []chunk       // Data to return, one chunk per read
+	held    []byte        // Response held to return with the next one
+	count   map[Fault]int // Number of each fault applied
+	closed  bool
+}
+
+// chunk is returned by a single read
+type chunk struct {
+	data  []byte
+	delay time.Duration
+	eof   bool
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	FAULT_NONE       Fault = iota
+	FAULT_LATENCY          // Delay the response
+	FAULT_CORRUPT          // Change one byte of the response
+	FAULT_SPLIT            // Return the response in two reads
+	FAULT_MERGE            // Return the response with the next one
+	FAULT_DROP             // Discard the response
+	FAULT_EOF              // Return io.EOF before the response, as a serial port does when a read times out
+	FAULT_DISCONNECT       // Close the connection, and fail further reads and writes
+	FAULT_MAX        = FAULT_DISCONNECT
+)
+
+const (
+	DEFAULT_LATENCY = 100 * time.Millisecond
+)
+
+var (
+	// Returned by reads and writes after FAULT_DISCONNECT or Close
+	ErrDisconnected = errors.New("fault: disconnected")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// New returns a connection which injects faults into responses read from
+// conn
+func New(conn io.ReadWriteCloser, cfg Config) *Conn {
+	self := new(Conn)
+	self.conn = conn
+	self.rand = rand.New(rand.NewSource(cfg.Seed))
+	self.rate = cfg.Rate
+	self.count = make(map[Fault]int)
+
+	// Set random faults
+	if self.faults = cfg.Faults; len(self.faults) == 0 {
+		for f := FAULT_LATENCY; f < FAULT_EOF; f++ {
+			self.faults = append(self.faults, f)
+		}
+	}
+
+	// Set latency
+	if self.latency = cfg.Latency; self.latency == 0 {
+		self.latency = DEFAULT_LATENCY
+	}
+
+	// Return the connection
+	return self
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (f Fault) String() string {
+	switch f {
+	case FAULT_NONE:
+		return "FAULT_NONE"
+	case FAULT_LATENCY:
+		return "FAULT_LATENCY"
+	case FAULT_CORRUPT:
+		return "FAULT_CORRUPT"
+	case FAULT_SPLIT:
+		return "FAULT_SPLIT"
+	case FAULT_MERGE:
+		return "FAULT_MERGE"
+	case FAULT_DROP:
+		return "FAULT_DROP"
+	case FAULT_EOF:
+		return "FAULT_EOF"
+	case FAULT_DISCONNECT:
+		return "FAULT_DISCONNECT"
+	default:
+		return "[?? Invalid Fault value]"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Inject applies faults to the next responses, one fault per response,
+// before any random faults
+func (self *Conn) Inject(faults ...Fault) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.script = append(self.script, faults...)
+}
+
+// SetRate sets the probability of a random fault for each response
+func (self *Conn) SetRate(rate float64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.rate = rate
+}
+
+// Count returns the number of responses the fault has been applied to
+func (self *Conn) Count(f Fault) int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.count[f]
+}
+
+// Read returns the responses from the connection with faults applied
+func (self *Conn) Read(data []byte) (int, error) {
+	for {
+		// Return the next chunk
+		self.mu.Lock()
+		if self.closed {
+			self.mu.Unlock()
+			return 0, ErrDisconnected
+		} else if len(self.out) > 0 {
+			c := self.out[0]
+			n := copy(data, c.data)
+			if n < len(c.data) {
+				self.out[0] = chunk{data: c.data[n:]}
+			} else {
+				self.out = self.out[1:]
+			}
+			self.mu.Unlock()
+			if c.delay > 0 {
+				time.Sleep(c.delay)
+			}
+			if c.eof {
+				return 0, io.EOF
+			}
+			return n, nil
+		}
+		self.mu.Unlock()
+
+		// Read from the connection and apply faults to any responses
+		buf := make([]byte, 1024)
+		n, err := self.conn.Read(buf)
+		self.mu.Lock()
+		self.receive(buf[:n])
+		empty := len(self.out) == 0
+		self.mu.Unlock()
+
+		// Return errors when there is nothing to return
+		if err != nil && empty {
+			return 0, err
+		}
+	}
+}
+
+// Write writes a command to the connection, unless disconnected
+func (self *Conn) Write(data []byte) (int, error) {
+	self.mu.Lock()
+	closed := self.closed
+	self.mu.Unlock()
+	if closed {
+		return 0, ErrDisconnected
+	}
+	return self.conn.Write(data)
+}
+
+// Close closes the connection
+func (self *Conn) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.disconnect()
+}
+
+// SetReadDeadline sets the read deadline of the connection, if supported
+func (self *Conn) SetReadDeadline(t time.Time) error {
+	if conn, ok := self.conn.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return conn.SetReadDeadline(t)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// receive appends data read and applies faults to complete responses. It
+// should be called with the lock held
+func (self *Conn) receive(data []byte) {
+	self.in = append(self.in, data...)
+	for !self.closed {
+		i := bytes.IndexByte(self.in, '$')
+		if i < 0 {
+			break
+		}
+		response := append([]byte(nil), self.in[:i+1]...)
+		self.in = self.in[i+1:]
+		self.inject(response)
+	}
+}
+
+// inject applies the next fault to a response. It should be called with
+// the lock held
+func (self *Conn) inject(response []byte) {
+	fault := self.next()
+	self.count[fault]++
+
+	// Any held response is returned first
+	data := append(self.held, response...)
+	self.held = nil
+
+	switch fault {
+	case FAULT_LATENCY:
+		self.out = append(self.out, chunk{data: data, delay: self.latency})
+	case FAULT_CORRUPT:
+		i := len(data) - len(response) + self.rand.Intn(len(response))
+		data[i] ^= byte(1 + self.rand.Intn(255))
+		self.out = append(self.out, chunk{data: data})
+	case FAULT_SPLIT:
+		i := len(data) - len(response) + self.rand.Intn(len(response))
+		if i == 0 {
+			i = 1
+		}
+		self.out = append(self.out, chunk{data: data[:i]}, chunk{data: data[i:]})
+	case FAULT_MERGE:
+		self.held = data
+	case FAULT_DROP:
+		if data = data[:len(data)-len(response)]; len(data) > 0 {
+			self.out = append(self.out, chunk{data: data})
+		}
+	case FAULT_EOF:
+		self.out = append(self.out, chunk{eof: true}, chunk{data: data})
+	case FAULT_DISCONNECT:
+		self.disconnect()
+	default:
+		self.out = append(self.out, chunk{data: data})
+	}
+}
+
+// next returns the next scripted fault, or a random fault. It should be
+// called with the lock held
+func (self *Conn) next() Fault {
+	if len(self.script) > 0 {
+		fault := self.script[0]
+		self.script = self.script[1:]
+		return fault
+	}
+	if self.rate > 0 && self.rand.Float64() < self.rate {
+		return self.faults[self.rand.Intn(len(self.faults))]
+	}
+	return FAULT_NONE
+}
+
+// disconnect closes the connection and discards any data not yet
+// returned. It should be called with the lock held
+func (self *Conn) disconnect() error {
+	if self.closed {
+		return nil
+	}
+	self.closed = true
+	self.in, self.out, self.held = nil, nil, nil
+	return self.conn.Close()
+}
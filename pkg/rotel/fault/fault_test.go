@@ -0,0 +1,160 @@
+package fault
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// device returns each string on a separate read, then io.EOF
+type device struct {
+	reads  []string
+	writes strings.Builder
+	closed bool
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Fault_001(t *testing.T) {
+	// Responses are returned unchanged without faults
+	conn := New(&device{reads: []string{"power=on$vol", "ume=20$"}}, Config{})
+	if reads := readAll(t, conn); reads != "power=on$|volume=20$" {
+		t.Error("unexpected reads:", reads)
+	}
+}
+
+func Test_Fault_002(t *testing.T) {
+	// Scripted faults are applied to successive responses
+	tests := []struct {
+		fault Fault
+		reads string
+	}{
+		{FAULT_NONE, "power=on$|volume=20$|source=cd$"},
+		{FAULT_LATENCY, "power=on$|volume=20$|source=cd$"},
+		{FAULT_MERGE, "power=on$volume=20$|source=cd$"},
+		{FAULT_DROP, "volume=20$|source=cd$"},
+		{FAULT_EOF, "EOF|power=on$|volume=20$|source=cd$"},
+		{FAULT_DISCONNECT, "disconnected"},
+	}
+	for _, test := range tests {
+		dev := &device{reads: []string{"power=on$", "volume=20$", "source=cd$"}}
+		conn := New(dev, Config{Latency: time.Millisecond})
+		conn.Inject(test.fault)
+		if reads := readAll(t, conn); reads != test.reads {
+			t.Errorf("%v: unexpected reads: %q", test.fault, reads)
+		}
+		if test.fault != FAULT_NONE && conn.Count(test.fault) != 1 {
+			t.Errorf("%v: unexpected count: %v", test.fault, conn.Count(test.fault))
+		}
+		if test.fault == FAULT_DISCONNECT && !dev.closed {
+			t.Errorf("%v: connection not closed", test.fault)
+		}
+	}
+}
+
+func Test_Fault_003(t *testing.T) {
+	// Split and corrupted responses keep their length
+	for _, fault := range []Fault{FAULT_SPLIT, FAULT_CORRUPT} {
+		conn := New(&device{reads: []string{"volume=20$"}}, Config{Seed: 1})
+		conn.Inject(fault)
+		reads := readAll(t, conn)
+		switch fault {
+		case FAULT_SPLIT:
+			if parts := strings.Split(reads, "|"); len(parts) != 2 || parts[0]+parts[1] != "volume=20$" {
+				t.Errorf("%v: unexpected reads: %q", fault, reads)
+			}
+		case FAULT_CORRUPT:
+			if len(reads) != len("volume=20$") || reads == "volume=20$" {
+				t.Errorf("%v: unexpected reads: %q", fault, reads)
+			}
+		}
+	}
+}
+
+func Test_Fault_004(t *testing.T) {
+	// Random faults are repeatable with the same seed
+	var result []string
+	for i := 0; i < 2; i++ {
+		var reads []string
+		for j := 0; j < 50; j++ {
+			reads = append(reads, "volume=20$")
+		}
+		conn := New(&device{reads: reads}, Config{Seed: 42, Rate: 0.5, Faults: []Fault{FAULT_SPLIT, FAULT_MERGE, FAULT_DROP}})
+		result = append(result, readAll(t, conn))
+	}
+	if result[0] != result[1] {
+		t.Error("random faults are not repeatable")
+	} else if !strings.Contains(result[0], "volume=20$volume=20$") {
+		t.Error("expected merged responses:", result[0])
+	}
+}
+
+func Test_Fault_005(t *testing.T) {
+	// Writes fail after a disconnect
+	dev := &device{reads: []string{"power=on$"}}
+	conn := New(dev, Config{})
+	if _, err := conn.Write([]byte("power?")); err != nil {
+		t.Fatal(err)
+	}
+	conn.Inject(FAULT_DISCONNECT)
+	readAll(t, conn)
+	if _, err := conn.Write([]byte("power?")); !errors.Is(err, ErrDisconnected) {
+		t.Error("unexpected error:", err)
+	}
+	if dev.writes.String() != "power?" {
+		t.Error("unexpected writes:", dev.writes.String())
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// readAll returns the data of each read separated by "|", until the
+// device returns io.EOF twice or the connection is disconnected
+func readAll(t *testing.T, conn *Conn) string {
+	t.Helper()
+	var reads []string
+	data := make([]byte, 256)
+	for eof := 0; eof < 2; {
+		n, err := conn.Read(data)
+		switch {
+		case errors.Is(err, ErrDisconnected):
+			return strings.Join(append(reads, "disconnected"), "|")
+		case err == io.EOF:
+			if dev := conn.conn.(*device); len(dev.reads) > 0 {
+				reads = append(reads, "EOF")
+			} else {
+				eof++
+			}
+		case err != nil:
+			t.Fatal(err)
+		case n > 0:
+			reads = append(reads, string(data[:n]))
+		}
+	}
+	return strings.Join(reads, "|")
+}
+
+func (dev *device) Read(data []byte) (int, error) {
+	if len(dev.reads) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(data, dev.reads[0])
+	dev.reads = dev.reads[1:]
+	return n, nil
+}
+
+func (dev *device) Write(data []byte) (int, error) {
+	return dev.writes.Write(data)
+}
+
+func (dev *device) Close() error {
+	dev.closed = true
+	return nil
+}
@@ -1,33 +1,52 @@
 package rotel
 
-import "strings"
+//go:generate go run ./protocol/internal/gen -spec protocol/amplifier.json,protocol/cd.json,protocol/tuner.json -rotel . -flags OFFLINE,DESIRED,CONFLICT
+
+import (
+	"strings"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
 
 ////////////////////////////////////////////////////////////////////////////////
 // TYPES
 
-// Flag provides flags on state changes
-type Flag uint16
+// Flag provides flags on state changes. The flags are generated from the
+// protocol description of each device by "go generate", followed by the
+// flags set by the driver
+type Flag uint32
 
 ////////////////////////////////////////////////////////////////////////////////
-// GLOBALS
+// LIFECYCLE
 
-const (
-	ROTEL_FLAG_POWER Flag = (1 << iota)
-	ROTEL_FLAG_VOLUME
-	ROTEL_FLAG_MUTE
-	ROTEL_FLAG_BASS
-	ROTEL_FLAG_TREBLE
-	ROTEL_FLAG_BALANCE
-	ROTEL_FLAG_SOURCE
-	ROTEL_FLAG_FREQ
-	ROTEL_FLAG_BYPASS
-	ROTEL_FLAG_SPEAKER
-	ROTEL_FLAG_DIMMER
-	ROTEL_FLAG_MODEL
-	ROTEL_FLAG_NONE Flag = 0
-	ROTEL_FLAG_MIN       = ROTEL_FLAG_POWER
-	ROTEL_FLAG_MAX       = ROTEL_FLAG_DIMMER
-)
+// ParseFlag returns flags from a string of flag names separated by "|",
+// as returned by String. The ROTEL_FLAG_ prefix is optional and names are
+// not case sensitive
+func ParseFlag(value string) (Flag, error) {
+	result := ROTEL_FLAG_NONE
+	if value = strings.TrimSpace(value); value == "" {
+		return result, nil
+	}
+FOR_LOOP:
+	for _, part := range strings.Split(value, "|") {
+		name := strings.ToUpper(strings.TrimSpace(part))
+		if !strings.HasPrefix(name, "ROTEL_FLAG_") {
+			name = "ROTEL_FLAG_" + name
+		}
+		if name == ROTEL_FLAG_NONE.FlagString() {
+			continue FOR_LOOP
+		}
+		for v := ROTEL_FLAG_MIN; v != 0 && v <= ROTEL_FLAG_MAX; v <<= 1 {
+			if name == v.FlagString() {
+				result |= v
+				continue FOR_LOOP
+			}
+		}
+		return ROTEL_FLAG_NONE, ErrBadParameter.Withf("flag: %q", strings.TrimSpace(part))
+	}
+	return result, nil
+}
 
 ////////////////////////////////////////////////////////////////////////////////
 // STRINGIFY
@@ -37,7 +56,7 @@ func (f Flag) String() string {
 		return f.FlagString()
 	}
 	str := ""
-	for v := ROTEL_FLAG_MIN; v <= ROTEL_FLAG_MAX; v <<= 1 {
+	for v := ROTEL_FLAG_MIN; v != 0 && v <= ROTEL_FLAG_MAX; v <<= 1 {
 		if v&f == v {
 			str += "|" + v.FlagString()
 		}
@@ -45,42 +64,24 @@ func (f Flag) String() string {
 	return strings.TrimPrefix(str, "|")
 }
 
-func (f Flag) FlagString() string {
-	switch f {
-	case ROTEL_FLAG_NONE:
-		return "ROTEL_FLAG_NONE"
-	case ROTEL_FLAG_POWER:
-		return "ROTEL_FLAG_POWER"
-	case ROTEL_FLAG_VOLUME:
-		return "ROTEL_FLAG_VOLUME"
-	case ROTEL_FLAG_MUTE:
-		return "ROTEL_FLAG_MUTE"
-	case ROTEL_FLAG_BASS:
-		return "ROTEL_FLAG_BASS"
-	case ROTEL_FLAG_TREBLE:
-		return "ROTEL_FLAG_TREBLE"
-	case ROTEL_FLAG_BALANCE:
-		return "ROTEL_FLAG_BALANCE"
-	case ROTEL_FLAG_SOURCE:
-		return "ROTEL_FLAG_SOURCE"
-	case ROTEL_FLAG_FREQ:
-		return "ROTEL_FLAG_FREQ"
-	case ROTEL_FLAG_BYPASS:
-		return "ROTEL_FLAG_BYPASS"
-	case ROTEL_FLAG_SPEAKER:
-		return "ROTEL_FLAG_SPEAKER"
-	case ROTEL_FLAG_DIMMER:
-		return "ROTEL_FLAG_DIMMER"
-	case ROTEL_FLAG_MODEL:
-		return "ROTEL_FLAG_MODEL"
-	default:
-		return "[?? Invalid Flag value]"
-	}
-}
-
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
 func (f Flag) Is(flag Flag) bool {
 	return f&flag == flag
 }
+
+// MarshalText returns the flags as a string
+func (f Flag) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText sets the flags from a string
+func (f *Flag) UnmarshalText(data []byte) error {
+	if v, err := ParseFlag(string(data)); err != nil {
+		return err
+	} else {
+		*f = v
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Code generated by protocol/internal/gen from amplifier.json, cd.json, tuner.json. DO NOT EDIT.
+
+package rotel
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ROTEL_FLAG_POWER Flag = (1 << iota)
+	ROTEL_FLAG_VOLUME
+	ROTEL_FLAG_MUTE
+	ROTEL_FLAG_BASS
+	ROTEL_FLAG_TREBLE
+	ROTEL_FLAG_BALANCE
+	ROTEL_FLAG_SOURCE
+	ROTEL_FLAG_FREQ
+	ROTEL_FLAG_BYPASS
+	ROTEL_FLAG_SPEAKER
+	ROTEL_FLAG_DIMMER
+	ROTEL_FLAG_MODEL
+	ROTEL_FLAG_MODE
+	ROTEL_FLAG_FORMAT
+	ROTEL_FLAG_CENTER
+	ROTEL_FLAG_SUBWOOFER
+	ROTEL_FLAG_SURROUND
+	ROTEL_FLAG_PHONO
+	ROTEL_FLAG_PLAY
+	ROTEL_FLAG_DISC
+	ROTEL_FLAG_TRACK
+	ROTEL_FLAG_TIME
+	ROTEL_FLAG_REPEAT
+	ROTEL_FLAG_RANDOM
+	ROTEL_FLAG_BAND
+	ROTEL_FLAG_CHANNEL
+	ROTEL_FLAG_PRESET
+	ROTEL_FLAG_TEXT
+	ROTEL_FLAG_OFFLINE
+	ROTEL_FLAG_DESIRED
+	ROTEL_FLAG_CONFLICT
+	ROTEL_FLAG_NONE Flag = 0
+	ROTEL_FLAG_MIN       = ROTEL_FLAG_POWER
+	ROTEL_FLAG_MAX       = ROTEL_FLAG_CONFLICT
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (f Flag) FlagString() string {
+	switch f {
+	case ROTEL_FLAG_NONE:
+		return "ROTEL_FLAG_NONE"
+	case ROTEL_FLAG_POWER:
+		return "ROTEL_FLAG_POWER"
+	case ROTEL_FLAG_VOLUME:
+		return "ROTEL_FLAG_VOLUME"
+	case ROTEL_FLAG_MUTE:
+		return "ROTEL_FLAG_MUTE"
+	case ROTEL_FLAG_BASS:
+		return "ROTEL_FLAG_BASS"
+	case ROTEL_FLAG_TREBLE:
+		return "ROTEL_FLAG_TREBLE"
+	case ROTEL_FLAG_BALANCE:
+		return "ROTEL_FLAG_BALANCE"
+	case ROTEL_FLAG_SOURCE:
+		return "ROTEL_FLAG_SOURCE"
+	case ROTEL_FLAG_FREQ:
+		return "ROTEL_FLAG_FREQ"
+	case ROTEL_FLAG_BYPASS:
+		return "ROTEL_FLAG_BYPASS"
+	case ROTEL_FLAG_SPEAKER:
+		return "ROTEL_FLAG_SPEAKER"
+	case ROTEL_FLAG_DIMMER:
+		return "ROTEL_FLAG_DIMMER"
+	case ROTEL_FLAG_MODEL:
+		return "ROTEL_FLAG_MODEL"
+	case ROTEL_FLAG_MODE:
+		return "ROTEL_FLAG_MODE"
+	case ROTEL_FLAG_FORMAT:
+		return "ROTEL_FLAG_FORMAT"
+	case ROTEL_FLAG_CENTER:
+		return "ROTEL_FLAG_CENTER"
+	case ROTEL_FLAG_SUBWOOFER:
+		return "ROTEL_FLAG_SUBWOOFER"
+	case ROTEL_FLAG_SURROUND:
+		return "ROTEL_FLAG_SURROUND"
+	case ROTEL_FLAG_PHONO:
+		return "ROTEL_FLAG_PHONO"
+	case ROTEL_FLAG_PLAY:
+		return "ROTEL_FLAG_PLAY"
+	case ROTEL_FLAG_DISC:
+		return "ROTEL_FLAG_DISC"
+	case ROTEL_FLAG_TRACK:
+		return "ROTEL_FLAG_TRACK"
+	case ROTEL_FLAG_TIME:
+		return "ROTEL_FLAG_TIME"
+	case ROTEL_FLAG_REPEAT:
+		return "ROTEL_FLAG_REPEAT"
+	case ROTEL_FLAG_RANDOM:
+		return "ROTEL_FLAG_RANDOM"
+	case ROTEL_FLAG_BAND:
+		return "ROTEL_FLAG_BAND"
+	case ROTEL_FLAG_CHANNEL:
+		return "ROTEL_FLAG_CHANNEL"
+	case ROTEL_FLAG_PRESET:
+		return "ROTEL_FLAG_PRESET"
+	case ROTEL_FLAG_TEXT:
+		return "ROTEL_FLAG_TEXT"
+	case ROTEL_FLAG_OFFLINE:
+		return "ROTEL_FLAG_OFFLINE"
+	case ROTEL_FLAG_DESIRED:
+		return "ROTEL_FLAG_DESIRED"
+	case ROTEL_FLAG_CONFLICT:
+		return "ROTEL_FLAG_CONFLICT"
+	default:
+		return "[?? Invalid Flag value]"
+	}
+}
@@ -0,0 +1,90 @@
+package rotel
+
+import (
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Flag_001(t *testing.T) {
+	// Model and processor flags are included in the string
+	tests := map[Flag]string{
+		ROTEL_FLAG_NONE:                         "ROTEL_FLAG_NONE",
+		ROTEL_FLAG_MODEL:                        "ROTEL_FLAG_MODEL",
+		ROTEL_FLAG_POWER | ROTEL_FLAG_MODEL:     "ROTEL_FLAG_POWER|ROTEL_FLAG_MODEL",
+		ROTEL_FLAG_MODE | ROTEL_FLAG_FORMAT:     "ROTEL_FLAG_MODE|ROTEL_FLAG_FORMAT",
+		ROTEL_FLAG_CENTER | ROTEL_FLAG_SURROUND: "ROTEL_FLAG_CENTER|ROTEL_FLAG_SURROUND",
+		ROTEL_FLAG_SUBWOOFER:                    "ROTEL_FLAG_SUBWOOFER",
+	}
+	for flag, expected := range tests {
+		if str := flag.String(); str != expected {
+			t.Errorf("expected %q, got %q", expected, str)
+		}
+	}
+}
+
+func Test_Flag_002(t *testing.T) {
+	// Every flag and combination round trips through the string
+	for v := ROTEL_FLAG_MIN; v != 0 && v <= ROTEL_FLAG_MAX; v <<= 1 {
+		for _, flag := range []Flag{v, v | ROTEL_FLAG_MIN, v | ROTEL_FLAG_MAX} {
+			if parsed, err := ParseFlag(flag.String()); err != nil {
+				t.Error(flag, err)
+			} else if parsed != flag {
+				t.Errorf("expected %v, got %v", flag, parsed)
+			}
+		}
+	}
+}
+
+func Test_Flag_003(t *testing.T) {
+	// The prefix is optional and names are not case sensitive
+	tests := map[string]Flag{
+		"":                                     ROTEL_FLAG_NONE,
+		"none":                                 ROTEL_FLAG_NONE,
+		"ROTEL_FLAG_NONE":                      ROTEL_FLAG_NONE,
+		"power":                                ROTEL_FLAG_POWER,
+		"Rotel_Flag_Volume":                    ROTEL_FLAG_VOLUME,
+		" power | VOLUME ":                     ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME,
+		"model|rotel_flag_model":               ROTEL_FLAG_MODEL,
+		"ROTEL_FLAG_MODE|format|none":          ROTEL_FLAG_MODE | ROTEL_FLAG_FORMAT,
+		ROTEL_FLAG_MAX.FlagString():            ROTEL_FLAG_MAX,
+		"center|Subwoofer|ROTEL_FLAG_SURROUND": ROTEL_FLAG_CENTER | ROTEL_FLAG_SUBWOOFER | ROTEL_FLAG_SURROUND,
+	}
+	for value, expected := range tests {
+		if flag, err := ParseFlag(value); err != nil {
+			t.Error(value, err)
+		} else if flag != expected {
+			t.Errorf("%q: expected %v, got %v", value, expected, flag)
+		}
+	}
+
+	// Unknown names are rejected
+	for _, value := range []string{"unknown", "power|", "rotel_flag_", "power,volume"} {
+		if _, err := ParseFlag(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func Test_Flag_004(t *testing.T) {
+	// Flags are marshalled as text, for example in JSON
+	for _, flag := range []Flag{ROTEL_FLAG_NONE, ROTEL_FLAG_POWER, ROTEL_FLAG_POWER | ROTEL_FLAG_SOURCE, ROTEL_FLAG_MAX} {
+		var result Flag
+		if data, err := flag.MarshalText(); err != nil {
+			t.Error(err)
+		} else if string(data) != flag.String() {
+			t.Errorf("expected %q, got %q", flag.String(), data)
+		} else if err := result.UnmarshalText(data); err != nil {
+			t.Error(err)
+		} else if result != flag {
+			t.Errorf("expected %v, got %v", flag, result)
+		}
+	}
+	var result Flag = ROTEL_FLAG_POWER
+	if err := result.UnmarshalText([]byte("source|unknown")); err == nil {
+		t.Error("expected an error")
+	} else if result != ROTEL_FLAG_POWER {
+		t.Error("unexpected change on error", result)
+	}
+}
@@ -0,0 +1,213 @@
+package rotel
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// FramingStats counts the data received which could not be framed into
+// responses
+type FramingStats struct {
+	Frames    uint64 `json:"frames"`    // Responses framed
+	Errors    uint64 `json:"errors"`    // Framing errors
+	Discarded uint64 `json:"discarded"` // Bytes discarded
+	Overflows uint64 `json:"overflows"` // Responses longer than the maximum length
+}
+
+// framer splits data received from a device into responses ending in "$".
+// Control bytes are discarded, but bytes above 0x7F are kept since station
+// text can contain them. The length of a response is limited, and when a
+// response does not start with a key known to the device, it is
+// resynchronised on the next known key
+type framer struct {
+	mu      sync.Mutex
+	max     int      // Maximum response length
+	keys    []string // Keys of responses from the device, or nil
+	buf     []byte   // Partial response
+	garbage bool     // Discarding control bytes
+	stats   FramingStats
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	DEFAULT_MAX_FRAME = 256
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newFramer(max int) *framer {
+	self := new(framer)
+	if self.max = max; self.max <= 0 {
+		self.max = DEFAULT_MAX_FRAME
+	}
+	return self
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// FramingStats returns the number of responses framed and framing errors
+func (self *transport) FramingStats() FramingStats {
+	self.framer.mu.Lock()
+	defer self.framer.mu.Unlock()
+	return self.framer.stats
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// write appends data received from the device, and returns any complete
+// responses without the "$" and an error for any data discarded
+func (self *framer) write(dev device, data []byte) ([]string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// Get the keys of responses from the device
+	if self.keys == nil {
+		self.keys = keysOf(dev)
+	}
+
+	var result error
+	var frames []string
+	for _, ch := range data {
+		switch {
+		case ch == '$':
+			self.garbage = false
+			frame, err := self.frame(string(self.buf))
+			if err != nil {
+				result = errors.Join(result, err)
+			}
+			if frame != "" {
+				frames = append(frames, frame)
+			}
+			self.buf = self.buf[:0]
+		case ch < 0x20 || ch == 0x7F:
+			// Discard the partial response and the control byte,
+			// with one error for each run of garbage
+			if !self.garbage {
+				self.stats.Errors++
+				result = errors.Join(result, ErrFraming.With(strconv.Quote(string(append(self.buf, ch)))))
+			}
+			self.garbage = true
+			self.discard(len(self.buf) + 1)
+		default:
+			self.garbage = false
+			self.buf = append(self.buf, ch)
+			if len(self.buf) > self.max {
+				self.stats.Overflows++
+				self.stats.Errors++
+				result = errors.Join(result, ErrFraming.Withf("response exceeds %d bytes", self.max))
+				self.overflow()
+			}
+		}
+	}
+
+	// Return the responses
+	return frames, result
+}
+
+// reset discards any partial response
+func (self *framer) reset() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.buf = self.buf[:0]
+	self.garbage = false
+}
+
+// frame returns a complete response, or an error if it could not be
+// resynchronised on a known key
+func (self *framer) frame(frame string) (string, error) {
+	if frame == "" {
+		return "", nil
+	}
+
+	// Return a valid response
+	if self.valid(frame) {
+		self.stats.Frames++
+		return frame, nil
+	}
+
+	// Resynchronise on the next valid response
+	for i := 1; i < len(frame); i++ {
+		if self.valid(frame[i:]) {
+			self.stats.Errors++
+			self.stats.Frames++
+			self.stats.Discarded += uint64(i)
+			return frame[i:], ErrFraming.With(strconv.Quote(frame[:i]))
+		}
+	}
+
+	// Discard the response
+	self.stats.Errors++
+	self.stats.Discarded += uint64(len(frame))
+	return "", ErrFraming.With(strconv.Quote(frame))
+}
+
+// valid returns true if the response is an error reply, or is well-formed
+// and starts with a known key
+func (self *framer) valid(frame string) bool {
+	if reDeviceError.MatchString(frame) {
+		return true
+	} else if !reResponse.MatchString(frame) {
+		return false
+	} else if self.keys == nil {
+		return true
+	}
+	key := frame[:strings.IndexByte(frame, '=')]
+	for _, k := range self.keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// overflow discards the start of a response which is too long, keeping
+// any data from the next known key or which could be the start of a key
+func (self *framer) overflow() {
+	for i := 1; i < len(self.buf); i++ {
+		for _, key := range self.keys {
+			if bytes.HasPrefix(self.buf[i:], []byte(key+"=")) {
+				self.discard(i)
+				return
+			}
+		}
+	}
+
+	// Keep the longest key
+	keep := 0
+	for _, key := range self.keys {
+		if len(key) > keep {
+			keep = len(key)
+		}
+	}
+	self.discard(len(self.buf) - keep)
+}
+
+// discard removes bytes from the start of the buffer and counts them,
+// where n may include a byte which was not added to the buffer
+func (self *framer) discard(n int) {
+	self.stats.Discarded += uint64(n)
+	if n > len(self.buf) {
+		n = len(self.buf)
+	}
+	self.buf = append(self.buf[:0], self.buf[n:]...)
+}
+
+// keysOf returns the keys of responses from a device, or nil if the
+// device does not report them
+func keysOf(dev device) []string {
+	if dev, ok := dev.(interface{ keys() []string }); ok {
+		return dev.keys()
+	}
+	return nil
+}
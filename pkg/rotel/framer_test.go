@@ -0,0 +1,122 @@
+package rotel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Framer_001(t *testing.T) {
+	// Responses are framed, and garbage is discarded
+	tests := []struct {
+		data   string
+		frames []string
+		errors uint64
+	}{
+		{"power=on$volume=20$", []string{"power=on", "volume=20"}, 0},
+		{"power=on$vol", []string{"power=on"}, 0},
+		{"$$power=on$", []string{"power=on"}, 0},
+		{"unknown_command$error=busy$", []string{"unknown_command", "error=busy"}, 0},
+		{"\x00\x13\x7fpower=on$", []string{"power=on"}, 1},
+		{"pow\x00er=on$volume=20$", []string{"volume=20"}, 2},
+		{"noise power=on$", []string{"power=on"}, 1},
+		{"ume=20$source=cd$", []string{"source=cd"}, 1},
+		{"xxupdate_mode=auto$", []string{"update_mode=auto"}, 1},
+		{strings.Repeat("x", 300) + "power=on$", []string{"power=on"}, 2},
+		{strings.Repeat("\x00", 300) + "power=on$", []string{"power=on"}, 1},
+	}
+	for _, test := range tests {
+		framer := newFramer(0)
+		frames, err := framer.write(new(state), []byte(test.data))
+		if !reflect.DeepEqual(frames, test.frames) {
+			t.Errorf("%q: unexpected frames %q", test.data, frames)
+		}
+		if stats := framer.stats; stats.Errors != test.errors {
+			t.Errorf("%q: unexpected errors %v: %v", test.data, stats.Errors, err)
+		} else if (err != nil) != (test.errors > 0) {
+			t.Errorf("%q: unexpected error: %v", test.data, err)
+		}
+	}
+}
+
+func Test_Framer_002(t *testing.T) {
+	// Line noise without a "$" does not grow the buffer
+	framer := newFramer(64)
+	for i := 0; i < 100; i++ {
+		framer.write(new(state), []byte(strings.Repeat("noise", 20)))
+		if len(framer.buf) > 64 {
+			t.Fatal("unexpected buffer length:", len(framer.buf))
+		}
+	}
+	if frames, _ := framer.write(new(state), []byte("power=on$")); !reflect.DeepEqual(frames, []string{"power=on"}) {
+		t.Errorf("unexpected frames %q", frames)
+	}
+	if framer.stats.Overflows == 0 || framer.stats.Discarded < 100*100 {
+		t.Error("unexpected stats:", framer.stats)
+	}
+}
+
+func Test_Framer_003(t *testing.T) {
+	// Devices without known keys accept any well-formed response
+	framer := newFramer(0)
+	if frames, _ := framer.write(nil, []byte("ume=20$")); !reflect.DeepEqual(frames, []string{"ume=20"}) {
+		t.Errorf("unexpected frames %q", frames)
+	}
+}
+
+func Test_Framer_004(t *testing.T) {
+	// Station text keeps bytes above 0x7F
+	framer := newFramer(0)
+	data := "rds=Caf\xe9 FM$dls=001,Radio 3 \xc2\xb7 Live$"
+	if frames, err := framer.write(new(tunerstate), []byte(data)); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(frames, []string{"rds=Caf\xe9 FM", "dls=001,Radio 3 \xc2\xb7 Live"}) {
+		t.Errorf("unexpected frames %q", frames)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// FUZZ TESTS
+
+func Fuzz_Framer(f *testing.F) {
+	f.Add([]byte("power=on$volume=20$"), 3)
+	f.Add([]byte("pow\x00er=on$volume=20$"), 5)
+	f.Add([]byte("noise power=on$unknown_command$"), 10)
+	f.Add([]byte("xxupdate_mode=auto$balance=L03$"), 0)
+	f.Fuzz(func(t *testing.T, data []byte, split int) {
+		if split < 0 || split > len(data) {
+			split = len(data) / 2
+		}
+
+		// Frame the data in one write
+		whole := newFramer(32)
+		frames, _ := whole.write(new(state), data)
+
+		// Frame the data in two writes
+		parts := newFramer(32)
+		a, _ := parts.write(new(state), data[:split])
+		b, _ := parts.write(new(state), data[split:])
+		if !reflect.DeepEqual(frames, append(a, b...)) {
+			t.Errorf("frames differ when split: %q and %q", frames, append(a, b...))
+		}
+		if whole.stats != parts.stats {
+			t.Errorf("stats differ when split: %v and %v", whole.stats, parts.stats)
+		}
+
+		// Check the buffer and responses
+		if len(whole.buf) > whole.max {
+			t.Error("buffer exceeds maximum length:", len(whole.buf))
+		}
+		for _, frame := range frames {
+			if !whole.valid(frame) || strings.Contains(frame, "$") {
+				t.Errorf("invalid frame %q", frame)
+			}
+		}
+		if whole.stats.Frames != uint64(len(frames)) {
+			t.Error("unexpected number of frames:", whole.stats.Frames)
+		}
+	})
+}
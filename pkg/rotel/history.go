@@ -0,0 +1,107 @@
+package rotel
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// HistoryEntry is a state transition, error or command sent to the device
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command,omitempty"` // Command sent to the device
+	Flag    Flag      `json:"flag,omitempty"`    // Values which changed
+	Error   string    `json:"error,omitempty"`
+	Old     Snapshot  `json:"old,omitempty"` // Device state before the change
+	New     Snapshot  `json:"new,omitempty"` // Device state after the change
+}
+
+// history is a bounded ring buffer of entries
+type history struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newHistory(size int) *history {
+	if size <= 0 {
+		size = DEFAULT_HISTORY
+	}
+	return &history{
+		entries: make([]HistoryEntry, size),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// History returns the entries recorded at or after since, oldest first.
+// Use a zero time to return all entries
+func (self *history) History(since time.Time) []HistoryEntry {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// Entries are oldest first from next when the buffer is full
+	entries := self.entries[:self.next]
+	if self.full {
+		entries = append(append([]HistoryEntry{}, self.entries[self.next:]...), entries...)
+	}
+
+	// Return entries since the time
+	result := make([]HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Time.Before(since) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// WriteHistory writes the entries recorded at or after since as JSON
+func (self *history) WriteHistory(w io.Writer, since time.Time) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(self.History(since))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// add appends an entry, replacing the oldest entry when full
+func (self *history) add(entry HistoryEntry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.entries[self.next] = entry
+	if self.next = (self.next + 1) % len(self.entries); self.next == 0 {
+		self.full = true
+	}
+}
+
+// command records a command sent to the device. Queries are not recorded
+func (self *history) command(cmd string) {
+	if strings.HasSuffix(cmd, "!") {
+		self.add(HistoryEntry{Time: time.Now(), Command: cmd})
+	}
+}
+
+// event records a state transition or error
+func (self *history) event(evt Event) {
+	entry := HistoryEntry{Time: evt.Time, Flag: evt.Flag}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if evt.Err != nil {
+		entry.Error = evt.Err.Error()
+	}
+	entry.Old, entry.New = evt.Old, evt.New
+	self.add(entry)
+}
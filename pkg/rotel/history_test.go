@@ -0,0 +1,139 @@
+package rotel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_History_001(t *testing.T) {
+	// Entries are returned oldest first, and the oldest entries are
+	// replaced when the buffer is full
+	self := newHistory(3)
+	if entries := self.History(time.Time{}); len(entries) != 0 {
+		t.Fatal("unexpected entries", entries)
+	}
+	for i := 1; i <= 5; i++ {
+		self.command(fmt.Sprintf("vol_%02d!", i))
+		if entries := self.History(time.Time{}); i < 3 && len(entries) != i {
+			t.Fatalf("%d: unexpected entries %v", i, entries)
+		}
+	}
+	entries := self.History(time.Time{})
+	if len(entries) != 3 {
+		t.Fatal("unexpected entries", entries)
+	}
+	for i, entry := range entries {
+		if expected := fmt.Sprintf("vol_%02d!", i+3); entry.Command != expected {
+			t.Errorf("%d: expected %q, got %q", i, expected, entry.Command)
+		}
+	}
+}
+
+func Test_History_002(t *testing.T) {
+	// Entries are returned at or after a time
+	self := newHistory(0)
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		self.event(Event{Flag: ROTEL_FLAG_VOLUME, Time: now.Add(time.Duration(i) * time.Second)})
+	}
+	for _, test := range []struct {
+		since time.Time
+		count int
+	}{
+		{time.Time{}, 4},
+		{now, 4},
+		{now.Add(time.Second), 3},
+		{now.Add(3 * time.Second), 1},
+		{now.Add(4 * time.Second), 0},
+	} {
+		entries := self.History(test.since)
+		if len(entries) != test.count {
+			t.Errorf("%v: expected %d entries, got %d", test.since.Sub(now), test.count, len(entries))
+		} else if len(entries) > 0 && entries[0].Time.Before(test.since) {
+			t.Errorf("%v: unexpected entry %v", test.since.Sub(now), entries[0])
+		}
+	}
+}
+
+func Test_History_003(t *testing.T) {
+	// Commands are recorded, and queries are not
+	self := newHistory(0)
+	for _, cmd := range []string{"model?", "power_on!", "volume?", "vol_30!"} {
+		self.command(cmd)
+	}
+	entries := self.History(time.Time{})
+	if len(entries) != 2 {
+		t.Fatal("unexpected entries", entries)
+	} else if entries[0].Command != "power_on!" || entries[1].Command != "vol_30!" {
+		t.Error("unexpected entries", entries)
+	}
+}
+
+func Test_History_004(t *testing.T) {
+	// Events are written with the state before and after the change, and
+	// commands and errors without a state
+	self := newHistory(0)
+	self.command("vol_31!")
+	self.event(Event{
+		Flag: ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE,
+		Time: time.Now(),
+		Old:  State{Model: "A14", Power: true, Volume: 30, Source: "cd"},
+		New:  State{Model: "A14", Power: true, Volume: 31, Source: "opt1"},
+	})
+	self.event(Event{Err: errors.New("timeout")})
+
+	var buf bytes.Buffer
+	if err := self.WriteHistory(&buf, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 3 {
+		t.Fatal("unexpected entries", entries)
+	}
+
+	// Command
+	if entries[0]["command"] != "vol_31!" {
+		t.Error("unexpected command", entries[0])
+	}
+	for _, key := range []string{"flag", "error", "old", "new"} {
+		if _, exists := entries[0][key]; exists {
+			t.Errorf("command: unexpected %q", key)
+		}
+	}
+
+	// Event
+	if entries[1]["flag"] != "ROTEL_FLAG_VOLUME|ROTEL_FLAG_SOURCE" {
+		t.Error("unexpected flag", entries[1]["flag"])
+	}
+	for key, expected := range map[string]map[string]interface{}{
+		"old": {"model": "A14", "power": true, "volume": float64(30), "source": "cd"},
+		"new": {"model": "A14", "power": true, "volume": float64(31), "source": "opt1"},
+	} {
+		snapshot, ok := entries[1][key].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: unexpected snapshot %v", key, entries[1][key])
+			continue
+		}
+		for field, value := range expected {
+			if snapshot[field] != value {
+				t.Errorf("%s: %s: expected %v, got %v", key, field, value, snapshot[field])
+			}
+		}
+	}
+
+	// Error
+	if entries[2]["error"] != "timeout" {
+		t.Error("unexpected error", entries[2])
+	} else if _, exists := entries[2]["time"]; !exists {
+		t.Error("error: missing time")
+	}
+}
@@ -0,0 +1,162 @@
+package rotel
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// persisted is the last known amplifier state as stored on disk
+type persisted struct {
+	Model     string   `json:"model,omitempty"`
+	Power     string   `json:"power,omitempty"`
+	Volume    string   `json:"volume,omitempty"`
+	Mute      string   `json:"mute,omitempty"`
+	Bass      string   `json:"bass,omitempty"`
+	Treble    string   `json:"treble,omitempty"`
+	Balance   []string `json:"balance,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Freq      string   `json:"freq,omitempty"`
+	Bypass    string   `json:"bypass,omitempty"`
+	Speaker   string   `json:"speaker,omitempty"`
+	Dimmer    string   `json:"dimmer,omitempty"`
+	Phono     string   `json:"phono,omitempty"`
+	Mode      string   `json:"mode,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	Center    string   `json:"center,omitempty"`
+	Subwoofer string   `json:"subwoofer,omitempty"`
+	Surround  string   `json:"surround,omitempty"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// load reads the last known state from a file as provisional values, which
+// are replaced as the amplifier reports each value. A missing file is
+// not an error
+func (this *state) load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var p persisted
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.provisional = &p
+
+	// Return success
+	return nil
+}
+
+// save writes the last known state to a file, replacing it atomically
+func (this *state) save(path string) error {
+	this.mu.RLock()
+	p := this.persist()
+	this.mu.RUnlock()
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// persist returns the state to store, using provisional values for any
+// value which has not been reported yet. It should be called with the
+// lock held
+func (this *state) persist() persisted {
+	p := this.provisional
+	if p == nil {
+		p = new(persisted)
+	}
+	balance := this.balance
+	if balance == nil {
+		balance = p.Balance
+	}
+	return persisted{
+		Model:     pick(this.model, p.Model),
+		Power:     pick(this.power, p.Power),
+		Volume:    pick(this.volume, p.Volume),
+		Mute:      pick(this.mute, p.Mute),
+		Bass:      pick(this.bass, p.Bass),
+		Treble:    pick(this.treble, p.Treble),
+		Balance:   balance,
+		Source:    pick(this.source, p.Source),
+		Freq:      pick(this.freq, p.Freq),
+		Bypass:    pick(this.bypass, p.Bypass),
+		Speaker:   pick(this.speaker, p.Speaker),
+		Dimmer:    pick(this.dimmer, p.Dimmer),
+		Phono:     pick(this.phono, p.Phono),
+		Mode:      pick(this.mode, p.Mode),
+		Format:    pick(this.format, p.Format),
+		Center:    pick(this.center, p.Center),
+		Subwoofer: pick(this.subwoofer, p.Subwoofer),
+		Surround:  pick(this.surround, p.Surround),
+	}
+}
+
+// persistEvents saves the state to a file when it changes, waiting until
+// there have been no changes for the delay, and once more when the events
+// channel is closed
+func (self *Rotel) persistEvents(events <-chan Event, delay time.Duration) error {
+	var result error
+	timer := time.NewTimer(delay)
+	timer.Stop()
+	defer timer.Stop()
+
+	dirty := false
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				if dirty {
+					result = self.state.save(self.file)
+				}
+				return result
+			}
+			if evt.Flag != ROTEL_FLAG_NONE {
+				dirty = true
+				timer.Reset(delay)
+			}
+		case <-timer.C:
+			if err := self.state.save(self.file); err != nil {
+				self.publish(newError(err))
+			} else {
+				dirty = false
+			}
+		}
+	}
+}
+
+// pick returns the value, or the provisional value if the value is unknown
+func pick(value, provisional string) string {
+	if value != "" {
+		return value
+	}
+	return provisional
+}
@@ -0,0 +1,144 @@
+package rotel
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Persist_001(t *testing.T) {
+	// A missing file is not an error, and a corrupt file is
+	dir := t.TempDir()
+	this := new(state)
+	if err := this.load(filepath.Join(dir, "missing.json")); err != nil {
+		t.Error(err)
+	} else if this.LastKnown().Provisional != ROTEL_FLAG_NONE {
+		t.Error("unexpected provisional values")
+	}
+
+	file := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(file, []byte(`{"model": "A14", "power":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := this.load(file); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := NewWithConn(Config{StateFile: file}, func() (io.ReadWriteCloser, error) {
+		return new(recorder), nil
+	}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func Test_Persist_002(t *testing.T) {
+	// Saved values are loaded as provisional values, which are only
+	// returned by LastKnown until confirmed
+	file := filepath.Join(t.TempDir(), "state.json")
+	this := new(state)
+	for _, param := range []string{"model=A14", "power=on", "volume=42", "source=opt1", "speaker=a_b"} {
+		if _, err := this.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	if err := this.save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	that := new(state)
+	if err := that.load(file); err != nil {
+		t.Fatal(err)
+	}
+	if s := that.LastKnown(); s.Volume != 42 || s.Source != "opt1" || !s.SpeakerB || !s.Power {
+		t.Error("unexpected last known state", s)
+	} else if !s.Provisional.Is(ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE) {
+		t.Error("unexpected provisional flags", s.Provisional)
+	}
+	if s := that.State(); s != (State{}) {
+		t.Error("unexpected state", s)
+	}
+	if that.Power() {
+		t.Error("provisional power is not confirmed")
+	}
+
+	// A confirmed value replaces the provisional value
+	that.Set("volume=30")
+	if s := that.LastKnown(); s.Volume != 30 || s.Provisional.Is(ROTEL_FLAG_VOLUME) {
+		t.Error("unexpected last known state", s)
+	}
+}
+
+func Test_Persist_003(t *testing.T) {
+	// The file is replaced atomically, without leaving temporary files
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(file, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	this := new(state)
+	this.Set("model=A14")
+	this.Set("power=standby")
+	for i := 0; i < 3; i++ {
+		if err := this.save(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, err := os.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 {
+		t.Error("unexpected files", entries)
+	}
+	that := new(state)
+	if err := that.load(file); err != nil {
+		t.Fatal(err)
+	} else if model := that.LastKnown().Model; model != "A14" {
+		t.Error("unexpected model", model)
+	}
+}
+
+func Test_Persist_004(t *testing.T) {
+	// The state is saved once changes stop, and when the events end
+	const delay = 300 * time.Millisecond
+	file := filepath.Join(t.TempDir(), "state.json")
+	self, _ := newRecorded(t, "model=A14", "power=standby")
+	self.file = file
+
+	events := make(chan Event)
+	done := make(chan error)
+	go func() {
+		done <- self.persistEvents(events, delay)
+	}()
+
+	// Changes in quick succession are not saved
+	for i := 0; i < 10; i++ {
+		events <- Event{Flag: ROTEL_FLAG_VOLUME}
+		time.Sleep(delay / 10)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("saved before changes stopped")
+	}
+
+	// Saved once the changes stop
+	for deadline := time.Now().Add(5 * delay); ; time.Sleep(delay / 10) {
+		if _, err := os.Stat(file); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("not saved after changes stopped")
+		}
+	}
+
+	// Saved again when the events end
+	os.Remove(file)
+	events <- Event{Flag: ROTEL_FLAG_POWER}
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Error("not saved when the events ended:", err)
+	}
+}
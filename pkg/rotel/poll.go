@@ -0,0 +1,184 @@
+package rotel
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Strategy is how the amplifier state is kept up to date
+type Strategy uint
+
+// poller re-queries all values on an adaptive schedule: every fast
+// interval after a command or change, backing off to the slow interval
+// while nothing changes
+type poller struct {
+	mu         sync.Mutex
+	strategy   Strategy
+	fast, slow time.Duration
+	interval   time.Duration // Current interval
+	next       time.Time     // Time of the next poll
+}
+
+// restorer is implemented by the state of devices which return a command
+// to restore the device settings on shutdown
+type restorer interface {
+	restore() string
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	STRATEGY_PUSH   Strategy = iota // The amplifier sends changes (rs232_update_on!)
+	STRATEGY_POLL                   // Values are queried, leaving the update mode unchanged
+	STRATEGY_HYBRID                 // The amplifier sends changes, and values are also queried
+)
+
+const (
+	DEFAULT_POLL_FAST = 2 * time.Second
+	DEFAULT_POLL_SLOW = time.Minute
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newPoller(cfg Config) *poller {
+	self := new(poller)
+	self.strategy = cfg.Strategy
+	if self.fast = cfg.PollFast; self.fast == 0 {
+		self.fast = DEFAULT_POLL_FAST
+	}
+	if self.slow = cfg.PollSlow; self.slow == 0 {
+		self.slow = DEFAULT_POLL_SLOW
+	}
+	if self.slow < self.fast {
+		self.slow = self.fast
+	}
+	self.interval = self.fast
+	return self
+}
+
+// ParseStrategy returns a strategy from its name: push, poll or hybrid
+func ParseStrategy(value string) (Strategy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "push", "":
+		return STRATEGY_PUSH, nil
+	case "poll":
+		return STRATEGY_POLL, nil
+	case "hybrid":
+		return STRATEGY_HYBRID, nil
+	default:
+		return STRATEGY_PUSH, ErrBadParameter.Withf("strategy: %q", value)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (s Strategy) String() string {
+	switch s {
+	case STRATEGY_PUSH:
+		return "push"
+	case STRATEGY_POLL:
+		return "poll"
+	case STRATEGY_HYBRID:
+		return "hybrid"
+	default:
+		return "[?? Invalid Strategy value]"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// MarshalText returns the strategy name
+func (s Strategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets the strategy from its name
+func (s *Strategy) UnmarshalText(data []byte) error {
+	if v, err := ParseStrategy(string(data)); err != nil {
+		return err
+	} else {
+		*s = v
+	}
+	return nil
+}
+
+// Set sets the strategy from a command-line flag
+func (s *Strategy) Set(value string) error {
+	return s.UnmarshalText([]byte(value))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// poll is called periodically with the next query for the device, and
+// returns the query to send. When polling and no other query is waiting,
+// it re-queries all values when due and backs off the interval
+func (self *transport) poll(dev device, cmd string) string {
+	if self.poller.strategy == STRATEGY_PUSH || cmd != "" || !self.poller.due() {
+		return cmd
+	}
+	if w, ok := dev.(watched); ok {
+		w.resync()
+		cmd = dev.Update(false)
+	}
+	return cmd
+}
+
+// due returns true when a poll is due, and backs off the interval for the
+// next poll
+func (self *poller) due() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	now := time.Now()
+	if now.Before(self.next) {
+		return false
+	}
+	if self.interval *= 2; self.interval > self.slow {
+		self.interval = self.slow
+	}
+	self.next = now.Add(self.interval)
+	return true
+}
+
+// active is called after a command is sent or a change is received, and
+// polls again after the fast interval
+func (self *poller) active() {
+	if self.strategy == STRATEGY_PUSH {
+		return
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.interval = self.fast
+	if next := time.Now().Add(self.fast); self.next.After(next) {
+		self.next = next
+	}
+}
+
+// restore returns the command to restore the update mode reported when
+// first connected, or an empty string if unchanged
+func (this *state) restore() string {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	switch {
+	case this.original == "" || this.original == this.update:
+		return ""
+	case this.original == "auto":
+		return string(protocol.SetUpdateMode(true).Encode())
+	default:
+		return string(protocol.SetUpdateMode(false).Encode())
+	}
+}
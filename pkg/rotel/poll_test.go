@@ -0,0 +1,116 @@
+package rotel_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	emulator "github.com/djthorpe/go-rotel/pkg/rotel/emulator"
+	fault "github.com/djthorpe/go-rotel/pkg/rotel/fault"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Poll_001(t *testing.T) {
+	// When polling, front panel changes are read without enabling updates
+	t.Parallel()
+	l, driver, _ := newLink(t, fault.Config{}, rotel.Config{
+		Strategy: rotel.STRATEGY_POLL,
+		PollFast: 100 * time.Millisecond,
+		PollSlow: 500 * time.Millisecond,
+	})
+	l.waitConsistent(t, driver, 20*time.Second)
+
+	// Change the source and volume from the front panel
+	l.FrontPanel("opt1")
+	l.FrontPanel("vol_up")
+	l.waitConsistent(t, driver, 20*time.Second)
+
+	// Updates are left disabled
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.amp.Update() {
+		t.Error("expected updates to be disabled")
+	}
+}
+
+func Test_Poll_002(t *testing.T) {
+	// The update mode is restored on shutdown
+	t.Parallel()
+	for _, strategy := range []rotel.Strategy{rotel.STRATEGY_PUSH, rotel.STRATEGY_HYBRID} {
+		driverConn, deviceConn, err := newTCPPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		amp := emulator.New(deviceConn, emulator.Config{State: rotel.State{Power: true, Volume: 30, Source: "cd", Freq: "off"}})
+		go amp.Run(context.Background())
+
+		// Run the driver until updates are enabled
+		ctx, cancel := context.WithCancel(context.Background())
+		driver, err := rotel.NewWithConn(rotel.Config{Strategy: strategy, Interval: 50 * time.Millisecond}, func() (io.ReadWriteCloser, error) {
+			return driverConn, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			driver.Run(ctx, nil)
+		}()
+		waitFor(t, 10*time.Second, "updates enabled", amp.Update)
+
+		// Stop the driver, which disables updates again
+		cancel()
+		wg.Wait()
+		waitFor(t, 5*time.Second, "updates disabled", func() bool {
+			return !amp.Update()
+		})
+	}
+}
+
+func Test_Poll_003(t *testing.T) {
+	// Strategies are parsed from their names
+	for _, strategy := range []rotel.Strategy{rotel.STRATEGY_PUSH, rotel.STRATEGY_POLL, rotel.STRATEGY_HYBRID} {
+		var v rotel.Strategy
+		if err := v.UnmarshalText([]byte(strategy.String())); err != nil {
+			t.Error(err)
+		} else if v != strategy {
+			t.Errorf("expected %v, got %v", strategy, v)
+		}
+	}
+	if _, err := rotel.ParseStrategy("interrupt"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// newTCPPair returns both ends of a loopback TCP connection, which unlike
+// net.Pipe buffers data so that neither end blocks writing while the other
+// is writing
+func newTCPPair() (net.Conn, net.Conn, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return client, server, nil
+}
@@ -0,0 +1,103 @@
+package rotel
+
+import (
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Profile describes the features of a Rotel model
+type Profile struct {
+	Model     string   // Model name, as reported by the device and normalised
+	Name      string   // Display name
+	Processor bool     // Surround modes, decoded format and channel trims
+	Phono     bool     // Phono MM/MC mode
+	Sources   []string // Input sources
+	VolumeMin uint     // Minimum volume
+	VolumeMax uint     // Maximum volume
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	MICHI_SOURCES = []string{
+		"pc_usb", "cd", "coax1", "coax2", "coax3", "opt1", "opt2", "opt3", "aux", "tuner", "phono", "usb", "bluetooth", "balanced",
+	}
+)
+
+var (
+	profiles = []Profile{
+		{Model: "a11", Name: "A11"},
+		{Model: "a12", Name: "A12"},
+		{Model: "a14", Name: "A14"},
+		{Model: "ra1570", Name: "RA-1570"},
+		{Model: "ra1572", Name: "RA-1572"},
+		{Model: "ra1592", Name: "RA-1592"},
+		{Model: "rsp1576", Name: "RSP-1576", Processor: true},
+		{Model: "rsp1582", Name: "RSP-1582", Processor: true},
+		{Model: "rap1580", Name: "RAP-1580", Processor: true},
+		{Model: "p5", Name: "Michi P5", Phono: true, Sources: MICHI_SOURCES, VolumeMax: 120},
+		{Model: "x3", Name: "Michi X3", Phono: true, Sources: MICHI_SOURCES, VolumeMax: 120},
+		{Model: "x5", Name: "Michi X5", Phono: true, Sources: MICHI_SOURCES, VolumeMax: 120},
+	}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// ProfileForModel returns the profile for a model name. If the model is
+// not known, an amplifier profile is returned
+func ProfileForModel(model string) Profile {
+	profile := Profile{Model: normaliseModel(model), Name: model}
+	for _, p := range profiles {
+		if p.Model == profile.Model {
+			profile = p
+			break
+		}
+	}
+
+	// Set defaults
+	if profile.Sources == nil {
+		profile.Sources = SOURCES
+	}
+	if profile.VolumeMin == 0 {
+		profile.VolumeMin = VOLUME_MIN
+	}
+	if profile.VolumeMax == 0 {
+		profile.VolumeMax = VOLUME_MAX
+	}
+
+	// Return the profile
+	return profile
+}
+
+// Models returns the display names of the known models
+func Models() []string {
+	result := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		result = append(result, p.Name)
+	}
+	return result
+}
+
+// HasSource returns true if the source is supported by the model
+func (p Profile) HasSource(value string) bool {
+	for _, source := range p.Sources {
+		if source == value {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// normaliseModel returns the model name in lowercase without separators,
+// and without the "michi" prefix
+func normaliseModel(model string) string {
+	key := strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(model))
+	return strings.TrimPrefix(key, "michi")
+}
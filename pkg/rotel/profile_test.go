@@ -0,0 +1,111 @@
+package rotel_test
+
+import (
+	"testing"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Profile_001(t *testing.T) {
+	// Model names are matched without case, separators or the Michi prefix
+	for model, name := range map[string]string{
+		"A14":      "A14",
+		"a14":      "A14",
+		"RSP-1576": "RSP-1576",
+		"rsp_1576": "RSP-1576",
+		"P5":       "Michi P5",
+		"Michi X3": "Michi X3",
+		"michi-x5": "Michi X5",
+	} {
+		if profile := rotel.ProfileForModel(model); profile.Name != name {
+			t.Errorf("%q: expected %q, got %q", model, name, profile.Name)
+		}
+	}
+}
+
+func Test_Profile_002(t *testing.T) {
+	// Features, sources and volume range depend on the model
+	a14 := rotel.ProfileForModel("A14")
+	if a14.Processor || a14.Phono {
+		t.Error("A14: unexpected features", a14)
+	}
+	if a14.VolumeMin != rotel.VOLUME_MIN || a14.VolumeMax != rotel.VOLUME_MAX {
+		t.Error("A14: unexpected volume range", a14.VolumeMin, a14.VolumeMax)
+	}
+	if !a14.HasSource("aux1") || a14.HasSource("balanced") {
+		t.Error("A14: unexpected sources", a14.Sources)
+	}
+
+	rsp := rotel.ProfileForModel("RSP-1576")
+	if !rsp.Processor || rsp.Phono {
+		t.Error("RSP-1576: unexpected features", rsp)
+	}
+
+	p5 := rotel.ProfileForModel("Michi P5")
+	if p5.Processor || !p5.Phono {
+		t.Error("P5: unexpected features", p5)
+	}
+	if p5.VolumeMax != 120 {
+		t.Error("P5: unexpected maximum volume", p5.VolumeMax)
+	}
+	if !p5.HasSource("balanced") || p5.HasSource("aux1") {
+		t.Error("P5: unexpected sources", p5.Sources)
+	}
+}
+
+func Test_Profile_003(t *testing.T) {
+	// Unknown models keep their name, with amplifier defaults
+	profile := rotel.ProfileForModel("RA-9999")
+	if profile.Name != "RA-9999" {
+		t.Error("unexpected name", profile.Name)
+	}
+	if profile.Processor || profile.Phono {
+		t.Error("unexpected features", profile)
+	}
+	if profile.VolumeMax != rotel.VOLUME_MAX || len(profile.Sources) != len(rotel.SOURCES) {
+		t.Error("unexpected defaults", profile)
+	}
+}
+
+func Test_Profile_004(t *testing.T) {
+	// Each known model has a profile with its name
+	models := rotel.Models()
+	if len(models) == 0 {
+		t.Fatal("no models")
+	}
+	for _, model := range models {
+		if profile := rotel.ProfileForModel(model); profile.Name != model {
+			t.Errorf("%q: unexpected profile %q", model, profile.Name)
+		}
+	}
+}
+
+func Test_Profile_005(t *testing.T) {
+	// The volume range of each model is within the range of the protocol,
+	// and the other ranges are the same as the protocol
+	volume, _ := protocol.Lookup("volume")
+	for _, model := range rotel.Models() {
+		if profile := rotel.ProfileForModel(model); int(profile.VolumeMin) < volume.Min || int(profile.VolumeMax) > volume.Max {
+			t.Errorf("%q: volume range %d to %d is outside %d to %d", model, profile.VolumeMin, profile.VolumeMax, volume.Min, volume.Max)
+		}
+	}
+	for _, test := range []struct {
+		device   protocol.Device
+		key      string
+		min, max int
+	}{
+		{protocol.AMPLIFIER, "bass", rotel.TONE_MIN, rotel.TONE_MAX},
+		{protocol.AMPLIFIER, "treble", rotel.TONE_MIN, rotel.TONE_MAX},
+		{protocol.AMPLIFIER, "center", rotel.TRIM_MIN, rotel.TRIM_MAX},
+		{protocol.TUNER, "preset", rotel.PRESET_MIN, rotel.PRESET_MAX},
+	} {
+		if spec, exists := test.device.Lookup(test.key); !exists || spec.Min != test.min || spec.Max != test.max {
+			t.Errorf("%s: expected range %d to %d, got %v", test.key, test.min, test.max, spec)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package protocol encodes commands to and decodes responses from Rotel
+// devices over RS232, without any I/O. Commands end in "!", queries end
+// in "?" and responses are "key=value$". The types of responses and of
+// commands which set a value are generated from the description of each
+// device, in amplifier.json, cd.json and tuner.json
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Command is a command or query sent to a device
+type Command interface {
+	Encode() []byte
+}
+
+// Query requests the value of a key, for example "volume"
+type Query string
+
+// Button is a command without a value, for example "vol_up" or "play"
+type Button string
+
+// Setter is a command which sets a value, and which can be checked
+// before it is sent
+type Setter interface {
+	Command
+	Valid() bool
+}
+
+// SetSpeaker switches speaker a or b
+type SetSpeaker struct {
+	Speaker string
+	On      bool
+}
+
+// SetFrequency tunes to a frequency in MHz for FM or kHz for AM
+type SetFrequency struct {
+	Band  string
+	Value float64
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (c Query) Encode() []byte {
+	return []byte(string(c) + "?")
+}
+
+func (c Button) Encode() []byte {
+	return command(string(c))
+}
+
+func (c SetSpeaker) Encode() []byte {
+	return command("speaker_" + c.Speaker + "_" + onOff(c.On))
+}
+
+func (c SetFrequency) Encode() []byte {
+	if strings.ToLower(c.Band) == "am" {
+		return command(fmt.Sprintf("freq_%.0f", c.Value))
+	}
+	return command(fmt.Sprintf("freq_%.2f", c.Value))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func command(cmd string) []byte {
+	return []byte(cmd + "!")
+}
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
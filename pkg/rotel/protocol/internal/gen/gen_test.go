@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Gen_001(t *testing.T) {
+	// The generated files are up to date with the specs, for the
+	// go:generate directive of each package
+	for _, file := range []string{"../../response.go", "../../../flag.go"} {
+		args := directive(t, file)
+		files, err := Run(filepath.Dir(file), args)
+		if err != nil {
+			t.Fatal(file, err)
+		} else if len(files) == 0 {
+			t.Fatal(file, "no files generated")
+		}
+		for path, expected := range files {
+			if data, err := ioutil.ReadFile(path); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(data, expected) {
+				t.Errorf("%s is out of date, run go generate", path)
+			}
+		}
+	}
+}
+
+func Test_Gen_002(t *testing.T) {
+	// Invalid specs are rejected
+	tests := []Spec{
+		{Device: "amplifier"},
+		{Device: "amplifier", State: "state", Flags: []string{"power"}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "power", Type: "Power", Kind: "bool"}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "volume", Type: "Volume", Kind: "uint", Format: "%d", Range: []int{10, 1}}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "Volume", Type: "Volume", Kind: "uint", Format: "%d"}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "source", Type: "Source", Kind: "string", Pattern: "("}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "source", Type: "Source", Kind: "text", Pattern: "\\w+"}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "rds", Type: "RDS", Kind: "string", Pattern: ".*", Prefix: "("}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "power", Type: "Power", Kind: "bool", Values: []string{"on", "off"}, Commands: []Command{{Type: "SetPower", Format: "power"}}}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "bass", Type: "Bass", Kind: "signed", Signs: []string{"+", "-"}, Commands: []Command{{Type: "SetBass", Format: "bass_%d", Signs: []string{"+", "-"}}}}}},
+		{Device: "amplifier", State: "state", Responses: []Response{{Key: "speaker", Type: "Speaker", Kind: "custom", Pattern: "a|b", Commands: []Command{{Type: "SetSpeaker", Format: "speaker_%s"}}}}},
+	}
+	for i, spec := range tests {
+		if err := spec.Check(); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
+
+func Test_Gen_003(t *testing.T) {
+	// Flags must be declared once by any device, and a type used by more
+	// than one device must be described in the same way
+	power := Response{Key: "power", Type: "Power", Kind: "bool", Values: []string{"on", "standby"}, Flag: "POWER"}
+	standby := Response{Key: "power", Type: "Power", Kind: "bool", Values: []string{"on", "off"}, Flag: "POWER"}
+	tests := []struct {
+		specs []*Spec
+		extra []string
+		valid bool
+	}{
+		{[]*Spec{{Flags: []string{"POWER"}, Responses: []Response{power}}, {Responses: []Response{power}}}, []string{"OFFLINE"}, true},
+		{[]*Spec{{Flags: []string{"POWER"}}, {Flags: []string{"POWER"}}}, nil, false},
+		{[]*Spec{{Flags: []string{"POWER"}}}, []string{"POWER"}, false},
+		{[]*Spec{{Responses: []Response{power}}}, []string{"OFFLINE"}, false},
+		{[]*Spec{{Flags: []string{"POWER"}, Responses: []Response{power}}, {Responses: []Response{standby}}}, nil, false},
+	}
+	for i, test := range tests {
+		if _, err := NewProtocol(test.specs, test.extra); test.valid && err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// directive returns the arguments of the go:generate directive which runs
+// the generator in a file
+func directive(t *testing.T, path string) []string {
+	t.Helper()
+	fh, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 3 && fields[0] == "//go:generate" && strings.HasSuffix(fields[3], "internal/gen") {
+			return fields[4:]
+		}
+	}
+	t.Fatal(path, "no go:generate directive")
+	return nil
+}
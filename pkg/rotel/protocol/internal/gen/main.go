@@ -0,0 +1,505 @@
+// Command gen generates the response and command types and parser tables
+// of the protocol package, and the flags and state setters of the rotel
+// package, from a JSON description of the protocol of each device. It is
+// run by "go generate" in each package
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Spec describes the responses from a device
+type Spec struct {
+	Device    string     `json:"device"`
+	State     string     `json:"state"`     // Type of the state in the rotel package
+	Flags     []string   `json:"flags"`     // Flags in bit order, without the ROTEL_FLAG_ prefix
+	Responses []Response `json:"responses"` // Responses in parser order
+	Source    string     `json:"-"`         // Name of the spec file
+}
+
+// Response describes a response, and the commands which change it
+type Response struct {
+	Key      string    `json:"key"`      // Key of the response, as in key=value$
+	Type     string    `json:"type"`     // Go type in the protocol package
+	Kind     string    `json:"kind"`     // string, enum, bool, uint, signed, float or custom
+	Doc      string    `json:"doc"`      // Comment for the type
+	Pattern  string    `json:"pattern"`  // Pattern of the value, for string and custom kinds
+	Prefix   string    `json:"prefix"`   // Pattern before the value which is discarded
+	Values   []string  `json:"values"`   // Values of an enum, or the true and false values of a bool
+	Format   string    `json:"format"`   // Format of a uint or float value
+	Signs    []string  `json:"signs"`    // Prefixes of positive and negative signed values
+	Range    []int     `json:"range"`    // Minimum and maximum value
+	Query    bool      `json:"query"`    // Value can be queried with "key?"
+	Commands []Command `json:"commands"` // Commands which set the value
+	Buttons  []string  `json:"buttons"`  // Other commands which change the value, without "!"
+	Feature  string    `json:"feature"`  // Only on models with the feature
+	Flag     string    `json:"flag"`     // Flag set when the value changes
+	Field    string    `json:"field"`    // Field of the state, defaults to the key
+	Setter   string    `json:"setter"`   // Method of the state which sets the value, if not the default
+}
+
+// Command describes a command which sets a value
+type Command struct {
+	Type    string            `json:"type"`    // Go type in the protocol package
+	Doc     string            `json:"doc"`     // Comment for the type
+	Format  string            `json:"format"`  // Format of the command without "!"
+	Values  []string          `json:"values"`  // The true and false values of a bool
+	Signs   []string          `json:"signs"`   // Prefixes of positive and negative signed values
+	Aliases map[string]string `json:"aliases"` // Values which are sent differently
+	Kind    string            `json:"-"`       // Kind of the value set
+	Range   []int             `json:"-"`       // Minimum and maximum value set
+	Enum    []string          `json:"-"`       // Values of an enum
+}
+
+// Protocol is the specs of all devices and the flags of the rotel package
+type Protocol struct {
+	Source   string     // Names of the spec files
+	Specs    []*Spec    // Specs in the order read
+	Flags    []string   // Flags in bit order, without the ROTEL_FLAG_ prefix
+	Types    []Response // Responses with distinct types
+	Commands []Command  // Commands with distinct types
+}
+
+// Output is a file generated from the specs
+type Output struct {
+	Path     string
+	Template *template.Template
+	Data     interface{}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	reKey    = regexp.MustCompile("^[a-z][a-z0-9_]*$")
+	reType   = regexp.MustCompile("^[A-Z]\\w*$")
+	reFlag   = regexp.MustCompile("^[A-Z][A-Z0-9_]*$")
+	reSigned = regexp.MustCompile("^(\\w*)%s%0?\\d*d$")
+)
+
+var (
+	funcs = template.FuncMap{
+		"pattern": Pattern,
+		"goType":  GoType,
+		"syntax":  Syntax,
+		"upper":   strings.ToUpper,
+		"dec":     func(v int) int { return v - 1 },
+		"quote":   func(v string) string { return fmt.Sprintf("%q", v) },
+		"zero":    func(format string) string { return reSigned.FindStringSubmatch(format)[1] + "000" },
+	}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// MAIN
+
+func main() {
+	files, err := Run(".", os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	// Write the files in order
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := ioutil.WriteFile(path, files[path], 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Run reads the specs and generates the files for the command line
+// arguments, where paths are relative to dir. It returns the contents of
+// each file by path
+func Run(dir string, args []string) (map[string][]byte, error) {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	specs := flags.String("spec", "amplifier.json,cd.json,tuner.json", "Protocol descriptions, separated by commas")
+	protocol := flags.String("protocol", "", "Directory of the protocol package to generate")
+	rotel := flags.String("rotel", "", "Directory of the rotel package to generate")
+	driver := flags.String("flags", "", "Flags of the rotel package which are not set by responses, separated by commas")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	} else if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %q", flags.Args())
+	}
+
+	// Read the specs
+	var s []*Spec
+	for _, path := range strings.Split(*specs, ",") {
+		if spec, err := ReadSpec(filepath.Join(dir, path)); err != nil {
+			return nil, err
+		} else {
+			s = append(s, spec)
+		}
+	}
+	var extra []string
+	if *driver != "" {
+		extra = strings.Split(*driver, ",")
+	}
+	p, err := NewProtocol(s, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the files
+	if *protocol != "" {
+		*protocol = filepath.Join(dir, *protocol)
+	}
+	if *rotel != "" {
+		*rotel = filepath.Join(dir, *rotel)
+	}
+	files := make(map[string][]byte)
+	for _, output := range p.Outputs(*protocol, *rotel) {
+		if data, err := Generate(output.Data, output.Template); err != nil {
+			return nil, fmt.Errorf("%s: %w", output.Path, err)
+		} else {
+			files[output.Path] = data
+		}
+	}
+	return files, nil
+}
+
+// ReadSpec reads and checks a spec
+func ReadSpec(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := new(Spec)
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := spec.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	spec.Source = filepath.Base(path)
+	return spec, nil
+}
+
+// NewProtocol returns the protocol for the specs, where the flags of the
+// rotel package are the flags of each spec followed by the extra flags
+func NewProtocol(specs []*Spec, extra []string) (*Protocol, error) {
+	p := new(Protocol)
+	sources := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		sources = append(sources, spec.Source)
+		p.Specs = append(p.Specs, spec)
+		p.Flags = append(p.Flags, spec.Flags...)
+	}
+	p.Source = strings.Join(sources, ", ")
+	p.Flags = append(p.Flags, extra...)
+
+	// Check the flags
+	flags := make(map[string]bool, len(p.Flags))
+	for _, flag := range p.Flags {
+		if !reFlag.MatchString(flag) || flags[flag] {
+			return nil, fmt.Errorf("invalid flag: %q", flag)
+		}
+		flags[flag] = true
+	}
+	if len(p.Flags) == 0 || len(p.Flags) > 32 {
+		return nil, fmt.Errorf("expected 1 to 32 flags, got %d", len(p.Flags))
+	}
+
+	// Collect the types, where a type used by more than one device must
+	// be described in the same way
+	types := make(map[string]Response)
+	commands := make(map[string]Command)
+	for _, spec := range specs {
+		for _, r := range spec.Responses {
+			if r.Flag != "" && !flags[r.Flag] {
+				return nil, fmt.Errorf("%s: %s: unknown flag: %q", spec.Source, r.Key, r.Flag)
+			}
+			if other, exists := types[r.Type]; !exists {
+				types[r.Type] = r
+				p.Types = append(p.Types, r)
+			} else if !reflect.DeepEqual(r.typeOf(), other.typeOf()) {
+				return nil, fmt.Errorf("%s: %s: type %s is described differently by another device", spec.Source, r.Key, r.Type)
+			}
+			for _, c := range r.Commands {
+				if other, exists := commands[c.Type]; !exists {
+					commands[c.Type] = c
+					p.Commands = append(p.Commands, c)
+				} else if !reflect.DeepEqual(c, other) {
+					return nil, fmt.Errorf("%s: %s: command %s is described differently by another device", spec.Source, r.Key, c.Type)
+				}
+			}
+		}
+	}
+	for _, c := range p.Commands {
+		if _, exists := types[c.Type]; exists {
+			return nil, fmt.Errorf("command type %s is also a response", c.Type)
+		}
+	}
+
+	// Return success
+	return p, nil
+}
+
+// Outputs returns the files to generate in the directories of the
+// protocol and rotel packages, where an empty directory is not generated
+func (p *Protocol) Outputs(protocol, rotel string) []Output {
+	var result []Output
+	if protocol != "" {
+		result = append(result,
+			Output{filepath.Join(protocol, "response_gen.go"), tmplResponse, p},
+			Output{filepath.Join(protocol, "command_gen.go"), tmplCommand, p},
+		)
+	}
+	if rotel != "" {
+		result = append(result, Output{filepath.Join(rotel, "flag_gen.go"), tmplFlag, p})
+		for _, spec := range p.Specs {
+			result = append(result, Output{filepath.Join(rotel, spec.State+"_gen.go"), tmplState, spec})
+		}
+	}
+	return result
+}
+
+// Generate returns a formatted source file
+func Generate(data interface{}, tmpl *template.Template) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// Check returns an error if the spec is incomplete or inconsistent, and
+// sets the kind and range of the value set by each command
+func (s *Spec) Check() error {
+	if !reKey.MatchString(s.Device) {
+		return fmt.Errorf("invalid device: %q", s.Device)
+	} else if !reKey.MatchString(s.State) {
+		return fmt.Errorf("invalid state: %q", s.State)
+	}
+	for _, flag := range s.Flags {
+		if !reFlag.MatchString(flag) {
+			return fmt.Errorf("invalid flag: %q", flag)
+		}
+	}
+
+	keys := make(map[string]bool, len(s.Responses))
+	types := make(map[string]bool, len(s.Responses))
+	for i, r := range s.Responses {
+		switch {
+		case !reKey.MatchString(r.Key) || keys[r.Key]:
+			return fmt.Errorf("invalid key: %q", r.Key)
+		case !reType.MatchString(r.Type) || types[r.Type]:
+			return fmt.Errorf("%s: invalid type: %q", r.Key, r.Type)
+		case len(r.Range) != 0 && (len(r.Range) != 2 || r.Range[0] > r.Range[1]):
+			return fmt.Errorf("%s: invalid range: %v", r.Key, r.Range)
+		}
+		if _, err := regexp.Compile(r.Prefix); err != nil {
+			return fmt.Errorf("%s: invalid prefix: %q", r.Key, r.Prefix)
+		}
+		switch r.Kind {
+		case "string", "custom":
+			if _, err := regexp.Compile(r.Pattern); err != nil || r.Pattern == "" {
+				return fmt.Errorf("%s: invalid pattern: %q", r.Key, r.Pattern)
+			}
+		case "enum":
+			if len(r.Values) == 0 {
+				return fmt.Errorf("%s: missing values", r.Key)
+			}
+		case "bool":
+			if len(r.Values) != 2 {
+				return fmt.Errorf("%s: expected true and false values", r.Key)
+			}
+		case "uint", "float":
+			if r.Format == "" {
+				return fmt.Errorf("%s: missing format", r.Key)
+			}
+		case "signed":
+			if len(r.Signs) != 2 {
+				return fmt.Errorf("%s: expected positive and negative signs", r.Key)
+			}
+		default:
+			return fmt.Errorf("%s: invalid kind: %q", r.Key, r.Kind)
+		}
+		for j := range r.Commands {
+			c := &s.Responses[i].Commands[j]
+			c.Kind, c.Range = r.Kind, r.Range
+			if r.Kind == "enum" {
+				c.Enum = r.Values
+			}
+			if err := c.Check(); err != nil {
+				return fmt.Errorf("%s: %w", r.Key, err)
+			}
+		}
+		keys[r.Key] = true
+		types[r.Type] = true
+	}
+	return nil
+}
+
+// Check returns an error if the command cannot be generated for the kind
+// of value it sets
+func (c *Command) Check() error {
+	if !reType.MatchString(c.Type) {
+		return fmt.Errorf("invalid command type: %q", c.Type)
+	}
+	switch c.Kind {
+	case "bool":
+		if len(c.Values) != 2 || !strings.Contains(c.Format, "%s") {
+			return fmt.Errorf("%s: expected a format for the true and false values", c.Type)
+		}
+	case "signed":
+		if len(c.Signs) != 2 || !reSigned.MatchString(c.Format) {
+			return fmt.Errorf("%s: expected a format for the sign and digits, and positive and negative signs", c.Type)
+		}
+	case "uint":
+		if !strings.Contains(c.Format, "d") || !strings.Contains(c.Format, "%") {
+			return fmt.Errorf("%s: expected a format for the value", c.Type)
+		}
+	case "string", "enum":
+		if !strings.Contains(c.Format, "%s") {
+			return fmt.Errorf("%s: expected a format for the value", c.Type)
+		}
+	default:
+		return fmt.Errorf("%s: commands for %s values are not generated", c.Type, c.Kind)
+	}
+	return nil
+}
+
+// Pattern returns the regular expression for the value of a response,
+// after any prefix. For signed values, the sign and digits are separate
+// groups
+func Pattern(r Response) string {
+	var pattern string
+	switch r.Kind {
+	case "enum", "bool":
+		values := make([]string, len(r.Values))
+		for i, value := range r.Values {
+			values[i] = regexp.QuoteMeta(value)
+		}
+		pattern = "(" + strings.Join(values, "|") + ")"
+	case "uint":
+		pattern = "(\\d+)"
+	case "float":
+		pattern = "(\\d+(?:\\.\\d+)?)"
+	case "signed":
+		pattern = "([" + regexp.QuoteMeta(strings.Join(r.Signs, "")) + "]?)(\\d+)"
+	default:
+		pattern = "(" + r.Pattern + ")"
+	}
+	return "^" + r.Prefix + pattern + "$"
+}
+
+// Syntax returns the commands which change a response, separated by
+// commas
+func Syntax(r Response) string {
+	var result []string
+	for _, c := range r.Commands {
+		switch c.Kind {
+		case "bool":
+			for _, value := range c.Values {
+				result = append(result, fmt.Sprintf(c.Format, value)+"!")
+			}
+		case "enum":
+			for _, value := range c.Enum {
+				result = append(result, fmt.Sprintf(c.Format, value)+"!")
+			}
+		default:
+			result = append(result, c.Format+"!")
+		}
+	}
+	for _, button := range r.Buttons {
+		result = append(result, button+"!")
+	}
+	return strings.Join(result, ", ")
+}
+
+// GoType returns the underlying Go type for a kind of value
+func GoType(kind string) string {
+	switch kind {
+	case "bool":
+		return "bool"
+	case "uint":
+		return "uint"
+	case "signed":
+		return "int"
+	case "float":
+		return "float64"
+	default:
+		return "string"
+	}
+}
+
+// StateField returns the field of the state which stores the value
+func (r Response) StateField() string {
+	if r.Field != "" {
+		return r.Field
+	}
+	return r.Key
+}
+
+// Min returns the minimum value, or zero
+func (r Response) Min() int {
+	if len(r.Range) == 2 {
+		return r.Range[0]
+	}
+	return 0
+}
+
+// Max returns the maximum value, or zero
+func (r Response) Max() int {
+	if len(r.Range) == 2 {
+		return r.Range[1]
+	}
+	return 0
+}
+
+// Formats returns true if the state setters format values with fmt
+func (s *Spec) Formats() bool {
+	for _, r := range s.Responses {
+		switch {
+		case r.Setter != "":
+			continue
+		case r.Kind == "uint", r.Kind == "signed", r.Kind == "float":
+			return true
+		}
+	}
+	return false
+}
+
+// Formats returns true if the commands format values with fmt
+func (p *Protocol) Formats() bool {
+	for _, c := range p.Commands {
+		if c.Kind != "bool" {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// typeOf returns the parts of a response which describe its type in the
+// protocol package
+func (r Response) typeOf() Response {
+	return Response{Key: r.Key, Type: r.Type, Kind: r.Kind, Pattern: r.Pattern, Prefix: r.Prefix, Values: r.Values, Format: r.Format, Signs: r.Signs}
+}
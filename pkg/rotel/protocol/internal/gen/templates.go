@@ -0,0 +1,263 @@
+package main
+
+import (
+	"text/template"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const header = `// Code generated by protocol/internal/gen from {{ .Source }}. DO NOT EDIT.
+
+`
+
+var (
+	tmplResponse = template.Must(template.New("response").Funcs(funcs).Parse(header + `package protocol
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Responses from devices
+type (
+{{- range .Types }}{{ if ne .Kind "custom" }}
+	{{ .Type }} {{ goType .Kind }}{{ if .Doc }} // {{ .Doc }}{{ end }}
+{{- end }}{{ end }}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+{{- range .Specs }}
+	{{ upper .Device }} Device = {{ quote .Device }}
+{{- end }}
+)
+
+var (
+	decoders = map[Device][]decoder{
+{{- range .Specs }}
+		{{ upper .Device }}: {
+{{- range .Responses }}
+			{ {{- quote .Key }}, regexp.MustCompile({{ quote (pattern .) }}), decode{{ .Type }}},
+{{- end }}
+		},
+{{- end }}
+	}
+	specs = map[Device][]Spec{
+{{- range .Specs }}
+		{{ upper .Device }}: {
+{{- range .Responses }}
+			{Key: {{ quote .Key }}, Query: {{ .Query }}, Command: {{ quote (syntax .) }}, Feature: {{ quote .Feature }}, Min: {{ .Min }}, Max: {{ .Max }}},
+{{- end }}
+		},
+{{- end }}
+	}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+{{ range .Types }}
+func (r {{ .Type }}) Key() string {
+	return {{ quote .Key }}
+}
+{{ if eq .Kind "bool" }}
+func (r {{ .Type }}) Encode() []byte {
+	if r {
+		return response(r, {{ quote (index .Values 0) }})
+	}
+	return response(r, {{ quote (index .Values 1) }})
+}
+{{ else if or (eq .Kind "uint") (eq .Kind "float") }}
+func (r {{ .Type }}) Encode() []byte {
+	return response(r, fmt.Sprintf({{ quote .Format }}, {{ goType .Kind }}(r)))
+}
+{{ else if eq .Kind "signed" }}
+func (r {{ .Type }}) Encode() []byte {
+	return response(r, padded(int(r), {{ quote (index .Signs 0) }}, {{ quote (index .Signs 1) }}))
+}
+{{ else if ne .Kind "custom" }}
+func (r {{ .Type }}) Encode() []byte {
+	return response(r, string(r))
+}
+{{ end }}{{ end }}
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+{{ range .Types }}{{ if eq .Kind "bool" }}
+func decode{{ .Type }}(args []string) (Response, error) {
+	return {{ .Type }}(args[0] == {{ quote (index .Values 0) }}), nil
+}
+{{ else if eq .Kind "uint" }}
+func decode{{ .Type }}(args []string) (Response, error) {
+	value, err := strconv.ParseUint(args[0], 10, 32)
+	return {{ .Type }}(value), err
+}
+{{ else if eq .Kind "float" }}
+func decode{{ .Type }}(args []string) (Response, error) {
+	value, err := strconv.ParseFloat(args[0], 64)
+	return {{ .Type }}(value), err
+}
+{{ else if eq .Kind "signed" }}
+func decode{{ .Type }}(args []string) (Response, error) {
+	value, err := strconv.ParseInt(args[1], 10, 32)
+	if args[0] == {{ quote (index .Signs 1) }} {
+		value = -value
+	}
+	return {{ .Type }}(value), err
+}
+{{ else if ne .Kind "custom" }}
+func decode{{ .Type }}(args []string) (Response, error) {
+	return {{ .Type }}(args[0]), nil
+}
+{{ end }}{{ end }}`))
+
+	tmplCommand = template.Must(template.New("command").Funcs(funcs).Parse(header + `package protocol
+{{ if .Formats }}
+import (
+	"fmt"
+)
+{{ end }}
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Commands which set a value
+type (
+{{- range .Commands }}
+	{{ .Type }} {{ goType .Kind }}{{ if .Doc }} // {{ .Doc }}{{ end }}
+{{- end }}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+{{ range .Commands }}
+func (c {{ .Type }}) Encode() []byte {
+{{- if .Aliases }}
+	switch c {
+{{- range $value, $alias := .Aliases }}
+	case {{ quote $value }}:
+		return command({{ quote $alias }})
+{{- end }}
+	}
+{{- end }}
+{{- if eq .Kind "bool" }}
+	if c {
+		return command({{ quote (printf .Format (index .Values 0)) }})
+	}
+	return command({{ quote (printf .Format (index .Values 1)) }})
+{{- else if eq .Kind "signed" }}
+	switch {
+	case c > 0:
+		return command(fmt.Sprintf({{ quote .Format }}, {{ quote (index .Signs 0) }}, int(c)))
+	case c < 0:
+		return command(fmt.Sprintf({{ quote .Format }}, {{ quote (index .Signs 1) }}, -int(c)))
+	default:
+		return command({{ quote (zero .Format) }})
+	}
+{{- else if eq .Kind "uint" }}
+	return command(fmt.Sprintf({{ quote .Format }}, uint(c)))
+{{- else }}
+	return command(fmt.Sprintf({{ quote .Format }}, string(c)))
+{{- end }}
+}
+
+// Valid returns true if the value can be sent to a device
+func (c {{ .Type }}) Valid() bool {
+{{- if .Range }}
+	return {{ if or (ne .Kind "uint") (gt (index .Range 0) 0) }}c >= {{ index .Range 0 }} && {{ end }}c <= {{ index .Range 1 }}
+{{- else if .Enum }}
+	switch c {
+	case {{ range $i, $value := .Enum }}{{ if $i }}, {{ end }}{{ quote $value }}{{ end }}:
+		return true
+	}
+	return false
+{{- else }}
+	return true
+{{- end }}
+}
+{{ end }}`))
+
+	tmplFlag = template.Must(template.New("flag").Funcs(funcs).Parse(header + `package rotel
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+{{- range $i, $flag := .Flags }}
+	ROTEL_FLAG_{{ $flag }}{{ if eq $i 0 }} Flag = (1 << iota){{ end }}
+{{- end }}
+	ROTEL_FLAG_NONE Flag = 0
+	ROTEL_FLAG_MIN       = ROTEL_FLAG_{{ index .Flags 0 }}
+	ROTEL_FLAG_MAX       = ROTEL_FLAG_{{ index .Flags (len .Flags | dec) }}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (f Flag) FlagString() string {
+	switch f {
+	case ROTEL_FLAG_NONE:
+		return "ROTEL_FLAG_NONE"
+{{- range .Flags }}
+	case ROTEL_FLAG_{{ . }}:
+		return "ROTEL_FLAG_{{ . }}"
+{{- end }}
+	default:
+		return "[?? Invalid Flag value]"
+	}
+}
+`))
+
+	tmplState = template.Must(template.New("state").Funcs(funcs).Parse(header + `package rotel
+
+import (
+{{- if .Formats }}
+	"fmt"
+{{- end }}
+	"strconv"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+
+	// Modules
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// set sets the state from a response, and returns the flag when the
+// value has changed. It should be called with the lock held
+func (this *{{ .State }}) set(response protocol.Response) (Flag, error) {
+	switch r := response.(type) {
+{{- range .Responses }}
+	case protocol.{{ .Type }}:
+{{- if .Setter }}
+		return this.{{ .Setter }}(r)
+{{- else if eq .Kind "uint" }}
+		return set(&this.{{ .StateField }}, fmt.Sprint(uint(r)), {{ template "flag" . }})
+{{- else if eq .Kind "signed" }}
+		return set(&this.{{ .StateField }}, fmt.Sprint(int(r)), {{ template "flag" . }})
+{{- else if eq .Kind "float" }}
+		return set(&this.{{ .StateField }}, fmt.Sprint(float64(r)), {{ template "flag" . }})
+{{- else if or (eq .Kind "string") (eq .Kind "enum") }}
+		return set(&this.{{ .StateField }}, string(r), {{ template "flag" . }})
+{{- else }}
+		return set(&this.{{ .StateField }}, value(r), {{ template "flag" . }})
+{{- end }}
+{{- end }}
+	default:
+		return 0, ErrUnexpectedResponse.With(strconv.Quote(string(response.Encode())))
+	}
+}
+
+// keys returns the keys of responses from the device
+func (this *{{ .State }}) keys() []string {
+	return protocol.{{ upper .Device }}.Keys()
+}
+{{ define "flag" }}{{ if .Flag }}ROTEL_FLAG_{{ .Flag }}{{ else }}ROTEL_FLAG_NONE{{ end }}{{ end }}`))
+)
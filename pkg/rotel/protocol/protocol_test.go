@@ -0,0 +1,193 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Protocol_001(t *testing.T) {
+	// Commands encode to the bytes sent to the device
+	tests := []struct {
+		cmd      Command
+		expected string
+	}{
+		{Query("volume"), "volume?"},
+		{Button("play"), "play!"},
+		{SetPower(true), "power_on!"},
+		{SetPower(false), "power_off!"},
+		{SetUpdateMode(true), "rs232_update_on!"},
+		{SetVolume(5), "vol_05!"},
+		{SetVolume(45), "vol_45!"},
+		{SetMute(true), "mute_on!"},
+		{SetBass(0), "bass_000!"},
+		{SetBass(4), "bass_+4!"},
+		{SetTreble(-10), "treble_-10!"},
+		{SetBalance(-3), "balance_l03!"},
+		{SetBalance(12), "balance_r12!"},
+		{SetBalance(0), "balance_000!"},
+		{SetSource("cd"), "cd!"},
+		{SetSource("pc_usb"), "pcusb!"},
+		{SetBypass(false), "bypass_off!"},
+		{SetDimmer(3), "dimmer_3!"},
+		{SetPhonoMode("mc"), "phono_mc!"},
+		{SetMode("stereo"), "stereo!"},
+		{SetSpeaker{Speaker: "b", On: true}, "speaker_b_on!"},
+		{SetCenter(-2), "center_-2!"},
+		{SetSubwoofer(0), "subwoofer_000!"},
+		{SetSurround(3), "surround_+3!"},
+		{SetBand("dab"), "band_dab!"},
+		{SetChannel("12B"), "channel_12B!"},
+		{RecallPreset(7), "preset_07!"},
+		{StorePreset(12), "store_12!"},
+		{SetRepeat("track"), "repeat_track!"},
+		{SetRandom(true), "random_on!"},
+		{SetFrequency{Band: "fm", Value: 98.5}, "freq_98.50!"},
+		{SetFrequency{Band: "am", Value: 1053}, "freq_1053!"},
+	}
+	for _, test := range tests {
+		if encoded := string(test.cmd.Encode()); encoded != test.expected {
+			t.Errorf("%#v: expected %q, got %q", test.cmd, test.expected, encoded)
+		}
+	}
+}
+
+func Test_Protocol_002(t *testing.T) {
+	// Responses decode to typed values, and encode back to the same bytes
+	tests := []struct {
+		response string
+		expected Response
+	}{
+		{"model=A14$", Model("A14")},
+		{"power=standby$", Power(false)},
+		{"update_mode=auto$", UpdateMode(true)},
+		{"volume=05$", Volume(5)},
+		{"mute=on$", Mute(true)},
+		{"bass=+05$", Bass(5)},
+		{"treble=-10$", Treble(-10)},
+		{"treble=000$", Treble(0)},
+		{"balance=L03$", Balance(-3)},
+		{"balance=R15$", Balance(15)},
+		{"source=pc_usb$", Source("pc_usb")},
+		{"freq=44.1$", Freq("44.1")},
+		{"bypass=off$", Bypass(false)},
+		{"speaker=a_b$", Speaker{A: true, B: true}},
+		{"speaker=b$", Speaker{B: true}},
+		{"speaker=off$", Speaker{}},
+		{"dimmer=2$", Dimmer(2)},
+		{"phono_mode=mm$", PhonoMode("mm")},
+		{"mode=stereo$", Mode("stereo")},
+		{"center=+02$", Center(2)},
+		{"subwoofer=-03$", Subwoofer(-3)},
+		{"error=power_off$", DeviceError("power_off")},
+		{"unknown_command$", DeviceError("unknown_command")},
+		{"play_status=play$", Param{Name: "play_status", Value: "play"}},
+		{"rds=Caf\xe9 FM$", Param{Name: "rds", Value: "Caf\xe9 FM"}},
+	}
+	for _, test := range tests {
+		response, err := Decode(test.response)
+		if err != nil {
+			t.Errorf("%q: %v", test.response, err)
+		} else if !reflect.DeepEqual(response, test.expected) {
+			t.Errorf("%q: expected %#v, got %#v", test.response, test.expected, response)
+		} else if encoded := string(response.Encode()); encoded != test.response {
+			t.Errorf("%q: encoded as %q", test.response, encoded)
+		}
+	}
+}
+
+func Test_Protocol_003(t *testing.T) {
+	// Malformed responses and invalid values are errors
+	for _, response := range []string{"", "volume", "volume=", "volume=loud", "power=off", "balance=C05", "speaker=c", "mute=on\x00"} {
+		if _, err := Decode(response); !errors.Is(err, ErrUnexpectedResponse) {
+			t.Errorf("%q: expected ErrUnexpectedResponse, got %v", response, err)
+		}
+	}
+}
+
+func Test_Protocol_004(t *testing.T) {
+	// Every typed response is described by the spec
+	for _, key := range Keys() {
+		if _, exists := Lookup(key); !exists {
+			t.Error("missing spec:", key)
+		}
+	}
+	if spec, exists := Lookup("balance"); !exists || spec.Min != -15 || spec.Max != 15 || !spec.Query {
+		t.Error("unexpected spec:", spec)
+	}
+	if _, exists := Lookup("play_status"); exists {
+		t.Error("unexpected spec for play_status")
+	}
+}
+
+func Test_Protocol_005(t *testing.T) {
+	// Commands check the range and values in the spec
+	tests := []struct {
+		cmd   Setter
+		valid bool
+	}{
+		{SetVolume(1), true},
+		{SetVolume(120), true},
+		{SetVolume(0), false},
+		{SetVolume(121), false},
+		{SetBass(-10), true},
+		{SetBass(11), false},
+		{SetBalance(-15), true},
+		{SetBalance(-16), false},
+		{SetDimmer(6), true},
+		{SetDimmer(7), false},
+		{SetPhonoMode("mc"), true},
+		{SetPhonoMode("mx"), false},
+		{SetRepeat("disc"), true},
+		{SetRepeat("all"), false},
+		{RecallPreset(30), true},
+		{StorePreset(0), false},
+		{SetSource("cd"), true},
+	}
+	for _, test := range tests {
+		if valid := test.cmd.Valid(); valid != test.valid {
+			t.Errorf("%#v: expected valid=%v", test.cmd, test.valid)
+		}
+	}
+}
+
+func Test_Protocol_006(t *testing.T) {
+	// Each device decodes its own responses
+	tests := []struct {
+		device   Device
+		response string
+		expected Response
+	}{
+		{CD, "status=pause$", Status("pause")},
+		{CD, "track=12$", Track(12)},
+		{CD, "time=3:07$", Time("3:07")},
+		{CD, "random=on$", Random(true)},
+		{TUNER, "freq=98.5$", Frequency(98.5)},
+		{TUNER, "preset=07$", Preset(7)},
+		{TUNER, "rds=012,Radio 3$", RDS("Radio 3")},
+		{TUNER, "dls=Live$", DLS("Live")},
+		{TUNER, "power=on$", Power(true)},
+		{AMPLIFIER, "status=pause$", Param{Name: "status", Value: "pause"}},
+	}
+	for _, test := range tests {
+		if response, err := test.device.Decode(test.response); err != nil {
+			t.Errorf("%s %q: %v", test.device, test.response, err)
+		} else if !reflect.DeepEqual(response, test.expected) {
+			t.Errorf("%s %q: expected %#v, got %#v", test.device, test.response, test.expected, response)
+		}
+	}
+	if _, err := AMPLIFIER.Decode("freq=98.5$"); err != nil {
+		t.Error(err)
+	} else if _, err := TUNER.Decode("freq=off$"); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Error("expected ErrUnexpectedResponse, got", err)
+	}
+	if keys := CD.Keys(); len(keys) != 9 || keys[3] != "status" {
+		t.Error("unexpected keys:", keys)
+	}
+}
@@ -0,0 +1,195 @@
+package protocol
+
+//go:generate go run ./internal/gen -protocol .
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Response is a value reported by a device
+type Response interface {
+	Key() string
+	Encode() []byte
+}
+
+// Speaker reports which speakers are on
+type Speaker struct {
+	A, B bool
+}
+
+// DeviceError is an error reported by the device
+type DeviceError string
+
+// Param is any other response, which has no typed value
+type Param struct {
+	Name, Value string
+}
+
+// Device is a type of device, which has its own responses
+type Device string
+
+// Spec describes a response, and the commands which change it
+type Spec struct {
+	Key      string
+	Query    bool   // Value can be queried with "key?"
+	Command  string // Syntax of the commands which change the value
+	Feature  string // Only on models with the feature, for example "processor"
+	Min, Max int    // Range of numeric values
+}
+
+// decoder returns a response from the groups matched by a pattern
+type decoder struct {
+	key string
+	re  *regexp.Regexp
+	fn  func(args []string) (Response, error)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// A well-formed response is a key, an equals sign and a value without
+	// control characters. Station text can contain bytes above 0x7F
+	reParam = regexp.MustCompile("^(\\w+)=([^\\x00-\\x1F\\x7F]*)$")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Keys returns the keys of amplifier responses which are decoded to typed
+// values, rather than to Param or DeviceError
+func Keys() []string {
+	return AMPLIFIER.Keys()
+}
+
+// Lookup returns the description of an amplifier response
+func Lookup(key string) (Spec, bool) {
+	return AMPLIFIER.Lookup(key)
+}
+
+// Decode returns the amplifier response for a single "key=value", with or
+// without the "$" terminator. Data received from a device is split into
+// responses by the driver before decoding
+func Decode(response string) (Response, error) {
+	return AMPLIFIER.Decode(response)
+}
+
+// Keys returns the keys of responses from the device which are decoded to
+// typed values, rather than to Param or DeviceError
+func (d Device) Keys() []string {
+	keys := make([]string, 0, len(specs[d]))
+	for _, spec := range specs[d] {
+		keys = append(keys, spec.Key)
+	}
+	return keys
+}
+
+// Lookup returns the description of a response from the device
+func (d Device) Lookup(key string) (Spec, bool) {
+	for _, spec := range specs[d] {
+		if spec.Key == key {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Decode returns the response from the device for a single "key=value",
+// with or without the "$" terminator. Responses without a typed value are
+// returned as Param
+func (d Device) Decode(response string) (Response, error) {
+	response = strings.TrimSuffix(response, "$")
+
+	// Errors without a value
+	switch response {
+	case "unknown_command", "invalid_command":
+		return DeviceError(response), nil
+	}
+
+	// Split the key and value
+	args := reParam.FindStringSubmatch(response)
+	if args == nil {
+		return nil, ErrUnexpectedResponse.With(strconv.Quote(response))
+	} else if args[1] == "error" {
+		return DeviceError(args[2]), nil
+	}
+	for _, decoder := range decoders[d] {
+		if decoder.key != args[1] {
+			continue
+		} else if values := decoder.re.FindStringSubmatch(args[2]); values == nil {
+			return nil, ErrUnexpectedResponse.With(strconv.Quote(response))
+		} else {
+			return decoder.fn(values[1:])
+		}
+	}
+	return Param{Name: args[1], Value: args[2]}, nil
+}
+
+func (r DeviceError) Key() string {
+	return "error"
+}
+
+func (r DeviceError) Encode() []byte {
+	switch r {
+	case "unknown_command", "invalid_command":
+		return []byte(string(r) + "$")
+	default:
+		return response(r, string(r))
+	}
+}
+
+func (r Speaker) Encode() []byte {
+	switch {
+	case r.A && r.B:
+		return response(r, "a_b")
+	case r.A:
+		return response(r, "a")
+	case r.B:
+		return response(r, "b")
+	default:
+		return response(r, "off")
+	}
+}
+
+func (r Param) Key() string {
+	return r.Name
+}
+
+func (r Param) Encode() []byte {
+	return response(r, r.Value)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func decodeSpeaker(args []string) (Response, error) {
+	return Speaker{
+		A: args[0] == "a" || args[0] == "a_b",
+		B: args[0] == "b" || args[0] == "a_b",
+	}, nil
+}
+
+func response(r Response, value string) []byte {
+	return []byte(r.Key() + "=" + value + "$")
+}
+
+// padded returns 000 for zero, or a two digit value with a prefix
+func padded(value int, positive, negative string) string {
+	switch {
+	case value > 0:
+		return fmt.Sprintf("%s%02d", positive, value)
+	case value < 0:
+		return fmt.Sprintf("%s%02d", negative, -value)
+	default:
+		return "000"
+	}
+}
@@ -0,0 +1,306 @@
+package rotel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Desired is a declared amplifier state. Only the fields of the state
+// flagged in Fields are applied, for example:
+//
+//	Desired{Fields: ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME, State: State{Power: true, Volume: 35}}
+type Desired struct {
+	State
+	Fields Flag // Fields of the state to apply
+}
+
+// reconciler converges the amplifier towards a desired state
+type reconciler struct {
+	mu      sync.Mutex
+	desired Desired
+	applied Flag      // Fields which have converged
+	pending Flag      // Fields last reported as pending
+	report  bool      // Report progress on the next step
+	sent    Flag      // Field for which a command was last sent
+	sentAt  time.Time // Time the command was sent
+	on      time.Time // Time the power was switched on
+	off     bool      // Power was last seen off
+	version uint      // Incremented when the desired state is replaced
+	wake    chan struct{}
+}
+
+// step is the work for one reconcile step, which is done without holding
+// the reconciler lock
+type step struct {
+	events  []Event
+	flag    Flag  // Field to apply, or ROTEL_FLAG_NONE
+	desired State // Desired state when the step was taken
+	current State // Current state when the step was taken
+	version uint  // Version of the desired state
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	deltaWarmup = 3 * time.Second // Time after power on before applying values
+)
+
+var (
+	// Order in which fields are applied
+	desiredOrder = []Flag{
+		ROTEL_FLAG_POWER, ROTEL_FLAG_SOURCE, ROTEL_FLAG_VOLUME, ROTEL_FLAG_BASS, ROTEL_FLAG_TREBLE,
+		ROTEL_FLAG_SPEAKER, ROTEL_FLAG_PHONO, ROTEL_FLAG_MODE, ROTEL_FLAG_CENTER, ROTEL_FLAG_SUBWOOFER,
+		ROTEL_FLAG_SURROUND,
+	}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newReconciler() *reconciler {
+	return &reconciler{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// SetDesired replaces the desired state. The amplifier is switched on first
+// if required, then once warmed up the remaining fields are applied in order.
+// Progress is reported with ROTEL_FLAG_DESIRED events, where Pending has the
+// fields not yet applied. If an applied field is changed elsewhere, for
+// example from the front panel, the field is no longer applied and an
+// ErrConflict event is emitted. Use a zero value to clear the desired state
+func (self *Rotel) SetDesired(desired Desired) error {
+	var supported Flag
+	for _, flag := range desiredOrder {
+		supported |= flag
+	}
+
+	// Check parameters
+	if fields := desired.Fields &^ supported; fields != ROTEL_FLAG_NONE {
+		return ErrUnsupported.With(fields)
+	} else if desired.Fields.Is(ROTEL_FLAG_POWER) && !desired.Power && desired.Fields != ROTEL_FLAG_POWER {
+		return ErrPowerOff.With("SetDesired")
+	}
+
+	// Set the desired state and wake the reconciler
+	self.reconciler.mu.Lock()
+	self.reconciler.desired = desired
+	self.reconciler.applied = ROTEL_FLAG_NONE
+	self.reconciler.pending = ROTEL_FLAG_NONE
+	self.reconciler.report = true
+	self.reconciler.sent = ROTEL_FLAG_NONE
+	self.reconciler.version++
+	self.reconciler.mu.Unlock()
+	select {
+	case self.reconciler.wake <- struct{}{}:
+	default:
+	}
+
+	// Return success
+	return nil
+}
+
+// Desired returns the desired state, and the fields not yet applied
+func (self *Rotel) Desired() (Desired, Flag) {
+	self.reconciler.mu.Lock()
+	defer self.reconciler.mu.Unlock()
+	return self.reconciler.desired, self.reconciler.desired.Fields &^ self.reconciler.applied
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// reconcile converges towards the desired state on each event, and
+// periodically, until the context is cancelled
+func (self *Rotel) reconcile(ctx context.Context, events <-chan Event) {
+	ticker := time.NewTicker(deltaUpdate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-ticker.C:
+		case <-self.reconciler.wake:
+		}
+		self.reconcileStep(time.Now())
+	}
+}
+
+// reconcileStep detects conflicts, reports progress and sends a command
+// for the next field which has not converged
+func (self *Rotel) reconcileStep(now time.Time) {
+	step := self.reconciler.next(self.State(), now)
+	for _, evt := range step.events {
+		self.publish(evt)
+	}
+	if step.flag == ROTEL_FLAG_NONE {
+		return
+	}
+	if err := self.apply(step.flag, step.desired, step.current); err != nil {
+		self.reconciler.drop(step.flag, step.version)
+		self.publish(newError(err))
+	}
+}
+
+// next returns the events to publish and the field to apply for the
+// current state
+func (self *reconciler) next(s State, now time.Time) step {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// Track when the power was switched on
+	if !s.Power {
+		self.off = true
+	} else if self.off {
+		self.off = false
+		self.on = now
+	}
+	result := step{desired: self.desired.State, current: s, version: self.version}
+	if self.desired.Fields == ROTEL_FLAG_NONE {
+		return result
+	}
+
+	diff := self.desired.diff(s)
+
+	// Applied fields which have changed are in conflict. When the power is
+	// off, other values are unknown
+	conflict := self.applied & diff
+	if !s.Power && !conflict.Is(ROTEL_FLAG_POWER) {
+		conflict = ROTEL_FLAG_NONE
+	}
+	if conflict.Is(ROTEL_FLAG_POWER) {
+		conflict |= self.desired.Fields
+	}
+	if conflict != ROTEL_FLAG_NONE {
+		self.desired.Fields &^= conflict
+		self.applied &^= conflict
+		result.events = append(result.events, Event{Flag: ROTEL_FLAG_CONFLICT | conflict, Err: ErrConflict.With(conflict), Time: now, New: s})
+	}
+
+	// Report progress
+	self.applied |= self.desired.Fields &^ diff
+	pending := self.desired.Fields &^ self.applied
+	if pending != self.pending || self.report {
+		self.pending, self.report = pending, false
+		result.events = append(result.events, Event{Flag: ROTEL_FLAG_DESIRED, Pending: pending, Time: now, New: s})
+	}
+
+	// Apply the next field, waiting for power on and warm-up, and for a
+	// response to any command sent
+	for _, flag := range desiredOrder {
+		if !pending.Is(flag) {
+			continue
+		}
+		if flag != ROTEL_FLAG_POWER && (!s.Power || now.Sub(self.on) < deltaWarmup) {
+			break
+		}
+		if self.sent == flag && now.Sub(self.sentAt) < deltaResponse {
+			break
+		}
+		self.sent, self.sentAt = flag, now
+		result.flag = flag
+		break
+	}
+
+	// Return the step
+	return result
+}
+
+// drop stops applying a field which could not be applied, unless the
+// desired state has been replaced since
+func (self *reconciler) drop(flag Flag, version uint) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.version == version {
+		self.desired.Fields &^= flag
+	}
+}
+
+// apply sends the command to change a field
+func (self *Rotel) apply(flag Flag, desired, current State) error {
+	switch flag {
+	case ROTEL_FLAG_POWER:
+		return self.SetPower(desired.Power)
+	case ROTEL_FLAG_SOURCE:
+		return self.SetSource(desired.Source)
+	case ROTEL_FLAG_VOLUME:
+		return self.SetVolume(desired.Volume)
+	case ROTEL_FLAG_BASS:
+		return self.SetBass(desired.Bass)
+	case ROTEL_FLAG_TREBLE:
+		return self.SetTreble(desired.Treble)
+	case ROTEL_FLAG_SPEAKER:
+		if desired.SpeakerA != current.SpeakerA {
+			if err := self.SetSpeaker(desired.SpeakerA, "a"); err != nil {
+				return err
+			}
+		}
+		if desired.SpeakerB != current.SpeakerB {
+			return self.SetSpeaker(desired.SpeakerB, "b")
+		}
+		return nil
+	case ROTEL_FLAG_PHONO:
+		return self.SetPhonoMode(desired.PhonoMode)
+	case ROTEL_FLAG_MODE:
+		return self.SetMode(desired.Mode)
+	case ROTEL_FLAG_CENTER:
+		return self.SetCenter(desired.Center)
+	case ROTEL_FLAG_SUBWOOFER:
+		return self.SetSubwoofer(desired.Subwoofer)
+	case ROTEL_FLAG_SURROUND:
+		return self.SetSurround(desired.Surround)
+	default:
+		return ErrUnsupported.With(flag)
+	}
+}
+
+// diff returns the desired fields which differ from the state
+func (d Desired) diff(s State) Flag {
+	var result Flag
+	if d.Power != s.Power {
+		result |= ROTEL_FLAG_POWER
+	}
+	if d.Source != s.Source {
+		result |= ROTEL_FLAG_SOURCE
+	}
+	if d.Volume != s.Volume {
+		result |= ROTEL_FLAG_VOLUME
+	}
+	if d.Bass != s.Bass {
+		result |= ROTEL_FLAG_BASS
+	}
+	if d.Treble != s.Treble {
+		result |= ROTEL_FLAG_TREBLE
+	}
+	if d.SpeakerA != s.SpeakerA || d.SpeakerB != s.SpeakerB {
+		result |= ROTEL_FLAG_SPEAKER
+	}
+	if d.PhonoMode != s.PhonoMode {
+		result |= ROTEL_FLAG_PHONO
+	}
+	if d.Mode != s.Mode {
+		result |= ROTEL_FLAG_MODE
+	}
+	if d.Center != s.Center {
+		result |= ROTEL_FLAG_CENTER
+	}
+	if d.Subwoofer != s.Subwoofer {
+		result |= ROTEL_FLAG_SUBWOOFER
+	}
+	if d.Surround != s.Surround {
+		result |= ROTEL_FLAG_SURROUND
+	}
+	return result & d.Fields
+}
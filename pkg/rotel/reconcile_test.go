@@ -0,0 +1,112 @@
+package rotel
+
+import (
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// reconcileTest is a reconcile step at a time after the start, and the
+// expected result
+type reconcileTest struct {
+	at       time.Duration
+	state    State
+	flag     Flag // Field applied
+	conflict Flag // Fields in conflict
+	pending  Flag // Fields not yet applied
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Reconcile_001(t *testing.T) {
+	// Fields are applied in order once the amplifier is on and warmed up,
+	// commands are sent again when there is no response, and fields changed
+	// elsewhere are in conflict
+	on := State{Power: true, Volume: 20, Source: "opt1"}
+	tests := []struct {
+		name    string
+		desired Desired
+		steps   []reconcileTest
+	}{
+		{"convergence", Desired{Fields: ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE, State: State{Power: true, Volume: 35, Source: "cd"}}, []reconcileTest{
+			{0, State{}, ROTEL_FLAG_POWER, ROTEL_FLAG_NONE, ROTEL_FLAG_POWER | ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE},
+			{time.Second, on, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE},
+			{time.Second + deltaWarmup, on, ROTEL_FLAG_SOURCE, ROTEL_FLAG_NONE, ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE},
+			{2*time.Second + deltaWarmup, State{Power: true, Volume: 20, Source: "cd"}, ROTEL_FLAG_VOLUME, ROTEL_FLAG_NONE, ROTEL_FLAG_VOLUME},
+			{3*time.Second + deltaWarmup, State{Power: true, Volume: 35, Source: "cd"}, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE},
+		}},
+		{"warmup", Desired{Fields: ROTEL_FLAG_SOURCE, State: State{Source: "cd"}}, []reconcileTest{
+			{0, State{}, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE},
+			{time.Second, on, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE},
+			{time.Second + deltaWarmup - time.Millisecond, on, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE},
+			{time.Second + deltaWarmup, on, ROTEL_FLAG_SOURCE, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE},
+			{2*time.Second + deltaWarmup, on, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE},
+			{time.Second + deltaWarmup + deltaResponse, on, ROTEL_FLAG_SOURCE, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE},
+		}},
+		{"conflict", Desired{Fields: ROTEL_FLAG_VOLUME | ROTEL_FLAG_SOURCE, State: State{Volume: 35, Source: "opt1"}}, []reconcileTest{
+			{0, on, ROTEL_FLAG_VOLUME, ROTEL_FLAG_NONE, ROTEL_FLAG_VOLUME},
+			{time.Second, State{Power: true, Volume: 35, Source: "opt1"}, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE},
+			{2 * time.Second, State{Power: true, Volume: 40, Source: "opt1"}, ROTEL_FLAG_NONE, ROTEL_FLAG_VOLUME, ROTEL_FLAG_NONE},
+			{3 * time.Second, State{Power: true, Volume: 20, Source: "cd"}, ROTEL_FLAG_NONE, ROTEL_FLAG_SOURCE, ROTEL_FLAG_NONE},
+		}},
+		{"power conflict", Desired{Fields: ROTEL_FLAG_POWER | ROTEL_FLAG_SOURCE, State: State{Power: true, Source: "opt1"}}, []reconcileTest{
+			{0, on, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE},
+			{time.Second, State{}, ROTEL_FLAG_NONE, ROTEL_FLAG_POWER | ROTEL_FLAG_SOURCE, ROTEL_FLAG_NONE},
+			{2 * time.Second, on, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE, ROTEL_FLAG_NONE},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newReconciler()
+			r.desired, r.report = test.desired, true
+			start := time.Now()
+			for i, s := range test.steps {
+				step := r.next(s.state, start.Add(s.at))
+				conflict := ROTEL_FLAG_NONE
+				for _, evt := range step.events {
+					if evt.Flag.Is(ROTEL_FLAG_CONFLICT) {
+						conflict |= evt.Flag &^ ROTEL_FLAG_CONFLICT
+					}
+				}
+				if step.flag != s.flag {
+					t.Errorf("step %d: expected %v to be applied, got %v", i, s.flag, step.flag)
+				}
+				if conflict != s.conflict {
+					t.Errorf("step %d: expected conflict %v, got %v", i, s.conflict, conflict)
+				}
+				if pending := r.desired.Fields &^ r.applied; pending != s.pending {
+					t.Errorf("step %d: expected pending %v, got %v", i, s.pending, pending)
+				}
+			}
+		})
+	}
+}
+
+func Test_Reconcile_002(t *testing.T) {
+	// A field which cannot be applied is dropped, unless the desired state
+	// has been replaced
+	self, conn := newRecorded(t, "model=A14", "power=on", "volume=20", "source=opt1")
+	if err := self.SetDesired(Desired{Fields: ROTEL_FLAG_SOURCE, State: State{Source: "dolby"}}); err != nil {
+		t.Fatal(err)
+	}
+	self.reconcileStep(time.Now())
+	if desired, pending := self.Desired(); desired.Fields != ROTEL_FLAG_NONE || pending != ROTEL_FLAG_NONE {
+		t.Error("unexpected desired fields", desired.Fields, pending)
+	}
+
+	if err := self.SetDesired(Desired{Fields: ROTEL_FLAG_SOURCE, State: State{Source: "cd"}}); err != nil {
+		t.Fatal(err)
+	}
+	version := self.reconciler.version
+	self.reconcileStep(time.Now())
+	if cmd := conn.String(); cmd != "cd!" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+	self.reconciler.drop(ROTEL_FLAG_SOURCE, version-1)
+	if _, pending := self.Desired(); pending != ROTEL_FLAG_SOURCE {
+		t.Error("unexpected pending fields", pending)
+	}
+}
@@ -0,0 +1,352 @@
+package rotel_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Package imports
+	goerrors "github.com/djthorpe/go-errors"
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	emulator "github.com/djthorpe/go-rotel/pkg/rotel/emulator"
+	fault "github.com/djthorpe/go-rotel/pkg/rotel/fault"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// link connects the driver to an emulated amplifier through a loopback
+// TCP connection which injects faults. Each time the driver reconnects, a new emulator
+// continues from the state of the last one
+type link struct {
+	ctx    context.Context
+	cfg    fault.Config
+	mu     sync.Mutex
+	amp    *emulator.Emulator
+	device net.Conn // Emulator end of the connection
+	conn   *fault.Conn
+	opened int
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Recovery_001(t *testing.T) {
+	// Split, merged, delayed and interrupted responses are framed correctly
+	t.Parallel()
+	l, driver, errs := newLink(t, fault.Config{Latency: 50 * time.Millisecond}, rotel.Config{})
+	l.Inject(
+		fault.FAULT_SPLIT, fault.FAULT_MERGE, fault.FAULT_LATENCY, fault.FAULT_LATENCY,
+		fault.FAULT_SPLIT, fault.FAULT_MERGE, fault.FAULT_MERGE, fault.FAULT_SPLIT,
+		fault.FAULT_SPLIT, fault.FAULT_LATENCY, fault.FAULT_SPLIT, fault.FAULT_MERGE,
+	)
+	l.waitConsistent(t, driver, 20*time.Second)
+	for _, err := range errs() {
+		if errors.Is(err, rotel.ErrFraming) || errors.Is(err, goerrors.ErrUnexpectedResponse) {
+			t.Error("unexpected error:", err)
+		}
+	}
+}
+
+func Test_Recovery_002(t *testing.T) {
+	// Corrupted and dropped responses are corrected by a resync
+	t.Parallel()
+	l, driver, errs := newLink(t, fault.Config{Seed: 1, Rate: 0.5}, rotel.Config{Resync: 2 * time.Second})
+	waitFor(t, 20*time.Second, "faults", func() bool {
+		return l.Count(fault.FAULT_CORRUPT) > 1 && l.Count(fault.FAULT_DROP) > 0
+	})
+	l.SetRate(0)
+	l.waitConsistent(t, driver, 30*time.Second)
+	if len(errs()) == 0 {
+		t.Error("expected errors from faults")
+	}
+}
+
+func Test_Recovery_003(t *testing.T) {
+	// The driver reconnects after a disconnect, and reads values which
+	// changed while disconnected
+	t.Parallel()
+	l, driver, _ := newLink(t, fault.Config{}, rotel.Config{})
+	l.waitConsistent(t, driver, 20*time.Second)
+
+	// Disconnect on the next response
+	l.Inject(fault.FAULT_DISCONNECT)
+	l.FrontPanel("vol_up")
+	waitFor(t, 5*time.Second, "offline", func() bool {
+		return driver.State().Offline
+	})
+
+	// Change the source while disconnected
+	l.FrontPanel("opt1")
+	l.waitConsistent(t, driver, 30*time.Second)
+	if state := driver.State(); state.Source != "opt1" || state.Volume != 31 {
+		t.Error("unexpected state:", state)
+	}
+	if l.Opened() != 2 {
+		t.Error("unexpected number of connections:", l.Opened())
+	}
+}
+
+func Test_Recovery_004(t *testing.T) {
+	// When the device closes the connection, the driver is offline until
+	// it reconnects, rather than waiting for responses
+	t.Parallel()
+	l, driver, errs := newLink(t, fault.Config{}, rotel.Config{Reconnect: time.Second})
+	l.waitConsistent(t, driver, 20*time.Second)
+
+	// Close the emulator end of the connection
+	l.Hangup()
+	waitFor(t, 5*time.Second, "offline", func() bool {
+		return driver.State().Offline
+	})
+	// The hangup is noticed when reading, rather than when a later write
+	// fails
+	reported := errs()
+	if len(reported) == 0 || !strings.HasPrefix(reported[0].Error(), "readtty:") {
+		t.Error("expected a read error, got:", reported)
+	}
+	for _, err := range reported {
+		if !errors.Is(err, rotel.ErrDisconnected) {
+			t.Error("unexpected error:", err)
+		}
+	}
+
+	// Reconnect
+	l.FrontPanel("opt1")
+	l.waitConsistent(t, driver, 20*time.Second)
+	if state := driver.State(); state.Source != "opt1" {
+		t.Error("unexpected state:", state)
+	}
+	if l.Opened() != 2 {
+		t.Error("unexpected number of connections:", l.Opened())
+	}
+}
+
+func Test_Recovery_005(t *testing.T) {
+	// The state and accessors are read while the driver runs and responses
+	// are received, which go test -race checks for data races
+	t.Parallel()
+	l, driver, _ := newLink(t, fault.Config{}, rotel.Config{})
+	l.waitConsistent(t, driver, 20*time.Second)
+
+	// Read the state while changes are made from the front panel
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				driver.State()
+				driver.LastKnown()
+				driver.Model()
+				driver.Power()
+				driver.Volume()
+				driver.Source()
+				driver.Speakers()
+				driver.SpeakerA()
+				driver.Balance()
+				driver.Muted()
+			}
+		}
+	}()
+	for _, cmd := range []string{"vol_up", "opt1", "vol_up", "cd", "vol_down"} {
+		l.FrontPanel(cmd)
+		time.Sleep(50 * time.Millisecond)
+	}
+	l.waitConsistent(t, driver, 20*time.Second)
+	close(done)
+	wg.Wait()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// newLink runs the driver against an emulated amplifier until the test
+// ends, and returns a function which returns the errors reported
+func newLink(t *testing.T, faults fault.Config, cfg rotel.Config) (*link, *rotel.Rotel, func() []error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &link{ctx: ctx, cfg: faults}
+
+	// Query and reconnect quickly
+	if cfg.Interval == 0 {
+		cfg.Interval = 50 * time.Millisecond
+	}
+	if cfg.Reconnect == 0 {
+		cfg.Reconnect = 200 * time.Millisecond
+	}
+
+	// Create the driver
+	driver, err := rotel.NewWithConn(cfg, l.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Collect errors
+	var mu sync.Mutex
+	var errs []error
+	events := driver.Subscribe(ctx, rotel.ROTEL_FLAG_NONE)
+
+	// Run until the test ends
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for evt := range events {
+			if evt.Err != nil {
+				mu.Lock()
+				errs = append(errs, evt.Err)
+				mu.Unlock()
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		driver.Run(ctx, nil)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	// Return the link
+	return l, driver, func() []error {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]error(nil), errs...)
+	}
+}
+
+// open connects to a new emulator
+func (l *link) open() (io.ReadWriteCloser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Continue from the state of the last emulator
+	state := rotel.State{Power: true, Volume: 30, Source: "cd", Freq: "off", SpeakerA: true, Bass: 2, Balance: -3}
+	if l.amp != nil {
+		state = l.amp.State()
+	}
+
+	// Run the emulator
+	driver, device, err := newTCPPair()
+	if err != nil {
+		return nil, err
+	}
+	l.device = device
+	l.amp = emulator.New(device, emulator.Config{State: state})
+	go l.amp.Run(l.ctx)
+
+	// Return the connection
+	l.conn = fault.New(driver, l.cfg)
+	l.opened++
+	return l.conn, nil
+}
+
+func (l *link) Inject(faults ...fault.Fault) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conn.Inject(faults...)
+}
+
+func (l *link) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conn.SetRate(rate)
+}
+
+func (l *link) Count(f fault.Fault) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conn.Count(f)
+}
+
+// Hangup closes the emulator end of the connection
+func (l *link) Hangup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.device.Close()
+}
+
+func (l *link) Opened() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.opened
+}
+
+// FrontPanel changes the emulator state, ignoring errors when the
+// emulator is disconnected
+func (l *link) FrontPanel(cmd string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.amp.FrontPanel(cmd)
+}
+
+// waitConsistent waits until the driver reports the emulator state
+func (l *link) waitConsistent(t *testing.T, driver *rotel.Rotel, timeout time.Duration) {
+	t.Helper()
+	var diff string
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		l.mu.Lock()
+		amp := l.amp.State()
+		l.mu.Unlock()
+		if diff = compare(driver.State(), amp); diff == "" {
+			return
+		}
+	}
+	t.Fatal("inconsistent state:", diff)
+}
+
+// waitFor waits until a condition is true
+func waitFor(t *testing.T, timeout time.Duration, name string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("timeout waiting for", name)
+}
+
+// compare returns the fields which differ between the driver and the
+// emulator, or an empty string
+func compare(driver, amp rotel.State) string {
+	if amp.Freq == "off" {
+		amp.Freq = ""
+	}
+	var diff string
+	for _, field := range []struct {
+		name          string
+		driver, value interface{}
+	}{
+		{"model", driver.Model, amp.Model},
+		{"power", driver.Power, amp.Power},
+		{"volume", driver.Volume, amp.Volume},
+		{"mute", driver.Mute, amp.Mute},
+		{"bass", driver.Bass, amp.Bass},
+		{"treble", driver.Treble, amp.Treble},
+		{"balance", driver.Balance, amp.Balance},
+		{"source", driver.Source, amp.Source},
+		{"freq", driver.Freq, amp.Freq},
+		{"bypass", driver.Bypass, amp.Bypass},
+		{"speaker_a", driver.SpeakerA, amp.SpeakerA},
+		{"speaker_b", driver.SpeakerB, amp.SpeakerB},
+		{"dimmer", driver.Dimmer, amp.Dimmer},
+		{"offline", driver.Offline, false},
+	} {
+		if field.driver != field.value {
+			diff += fmt.Sprintf(" %s=%v (expected %v)", field.name, field.driver, field.value)
+		}
+	}
+	return diff
+}
@@ -5,14 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	// Packages
-
-	term "github.com/pkg/term"
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
 
 	// Namespace imports
 	. "github.com/djthorpe/go-errors"
@@ -24,15 +21,35 @@ import (
 // TYPES
 
 type Config struct {
-	TTY     string        `yaml:"tty"`
-	Baud    uint          `yaml:"baud"`
-	Timeout time.Duration `yaml:"timeout"`
+	TTY       string        `yaml:"tty"`
+	Baud      uint          `yaml:"baud"`
+	Parity    Parity        `yaml:"parity"`    // Parity: none, odd or even
+	DataBits  uint          `yaml:"data_bits"` // Data bits, from 5 to 8
+	StopBits  uint          `yaml:"stop_bits"` // Stop bits, 1 or 2
+	Flow      Flow          `yaml:"flow"`      // Flow control: none, software or hardware
+	DTR       Signal        `yaml:"dtr"`       // DTR line set when opened: on, off or default
+	RTS       Signal        `yaml:"rts"`       // RTS line set when opened: on, off or default
+	Timeout   time.Duration `yaml:"timeout"`
+	StateFile string        `yaml:"state_file"` // Optional file for persisting state
+	Keepalive time.Duration `yaml:"keepalive"`  // Idle time before a keepalive query
+	Silence   time.Duration `yaml:"silence"`    // Silence before the device is offline
+	Resync    time.Duration `yaml:"resync"`     // Interval between re-querying all values
+	Defer     time.Duration `yaml:"defer"`      // Time to queue commands while powering on, or zero
+	History   int           `yaml:"history"`    // Number of history entries to keep
+	MaxFrame  int           `yaml:"max_frame"`  // Maximum response length
+	Strategy  Strategy      `yaml:"strategy"`   // Update strategy: push, poll or hybrid
+	PollFast  time.Duration `yaml:"poll_fast"`  // Interval between polls after a command or change
+	PollSlow  time.Duration `yaml:"poll_slow"`  // Longest interval between polls when idle
+	Interval  time.Duration `yaml:"interval"`   // Time between queries
+	Reconnect time.Duration `yaml:"reconnect"`  // Time between attempts to reopen the connection
 }
 
 type Rotel struct {
 	state
-	fd  *term.Term // TTY file handle
-	buf *strings.Builder
+	*transport
+	reconciler *reconciler
+	deferral   *deferral
+	file       string // File for persisting state, or empty
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -42,55 +59,73 @@ const (
 	DEFAULT_TTY         = "/dev/ttyUSB0"
 	DEFAULT_TTY_BAUD    = 115200
 	DEFAULT_TTY_TIMEOUT = 100 * time.Millisecond
-	deltaUpdate         = 500 * time.Millisecond
+	DEFAULT_KEEPALIVE   = 30 * time.Second
+	DEFAULT_SILENCE     = 90 * time.Second
+	DEFAULT_RESYNC      = 15 * time.Minute
+	DEFAULT_HISTORY     = 256
+	DEFAULT_INTERVAL    = 500 * time.Millisecond
+	DEFAULT_RECONNECT   = 5 * time.Second
+	deltaUpdate         = DEFAULT_INTERVAL
+	deltaResponse       = 2 * time.Second
+	deltaPersist        = 5 * time.Second
 	VOLUME_MIN          = 1
 	VOLUME_MAX          = 96
 	TONE_MIN            = -10 // Bass and treble
 	TONE_MAX            = 10  // Bass and treble
+	TRIM_MIN            = -10 // Center, subwoofer and surround
+	TRIM_MAX            = 10  // Center, subwoofer and surround
 )
 
 var (
 	SOURCES = []string{
 		"pc_usb", "cd", "coax1", "coax2", "opt1", "opt2", "aux1", "aux2", "tuner", "phono", "usb", "bluetooth",
 	}
+	MODES = []string{
+		"stereo", "dolby_pl2_movie", "dolby_pl2_music", "dolby_3stereo", "dts_neo6_cinema", "dts_neo6_music", "multi", "5ch_stereo",
+	}
+	PHONO_MODES = []string{
+		"mm", "mc",
+	}
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
 func NewWithConfig(cfg Config) (*Rotel, error) {
-	self := new(Rotel)
-
-	// Set tty from config
-	if cfg.TTY == "" {
-		cfg.TTY = DEFAULT_TTY
-	}
-	if cfg.Baud == 0 {
-		cfg.Baud = DEFAULT_TTY_BAUD
-	}
-	if cfg.Timeout == 0 {
-		cfg.Timeout = DEFAULT_TTY_TIMEOUT
-	}
-
-	// Check parameters
-	if _, err := os.Stat(cfg.TTY); os.IsNotExist(err) {
-		return nil, ErrBadParameter.With("tty: ", strconv.Quote(cfg.TTY))
-	} else if err != nil {
+	if transport, err := newTransport(cfg); err != nil {
 		return nil, err
+	} else {
+		return newWithTransport(cfg, transport)
 	}
+}
 
-	// Open term
-	if fd, err := term.Open(cfg.TTY, term.Speed(int(cfg.Baud)), term.RawMode); err != nil {
+// NewWithConn returns a driver for the connection returned by open, for
+// example a serial port server on the network, ignoring the TTY settings
+// in the configuration. Reads time out using SetReadDeadline when the
+// connection implements it, and open is called again to reconnect when
+// the connection fails
+func NewWithConn(cfg Config, open func() (io.ReadWriteCloser, error)) (*Rotel, error) {
+	if transport, err := newTransportWith(cfg, open); err != nil {
 		return nil, err
 	} else {
-		self.fd = fd
-		self.buf = new(strings.Builder)
+		return newWithTransport(cfg, transport)
 	}
+}
 
-	// Set term read timeout
-	if err := self.fd.SetReadTimeout(cfg.Timeout); err != nil {
-		defer self.fd.Close()
-		return nil, err
+func newWithTransport(cfg Config, transport *transport) (*Rotel, error) {
+	self := new(Rotel)
+	self.reconciler = newReconciler()
+	self.deferral = newDeferral(cfg.Defer)
+	self.transport = transport
+	self.state.strategy = cfg.Strategy
+
+	// Load the last known state
+	if cfg.StateFile != "" {
+		if err := self.state.load(cfg.StateFile); err != nil {
+			self.fd.Close()
+			return nil, fmt.Errorf("%s: %w", cfg.StateFile, err)
+		}
+		self.file = cfg.StateFile
 	}
 
 	// Return success
@@ -98,254 +133,210 @@ func NewWithConfig(cfg Config) (*Rotel, error) {
 }
 
 func (self *Rotel) Run(ctx context.Context, ch chan<- Event) error {
-	// Update rotel status every 100ms
-	timer := time.NewTimer(100 * time.Millisecond)
-	defer timer.Stop()
-
-	// Loop handling messages until done
-FOR_LOOP:
-	for {
-		select {
-		case <-ctx.Done():
-			break FOR_LOOP
-		case <-timer.C:
-			if cmd := self.state.Update(false); cmd != "" {
-				if err := self.writetty(cmd); err != nil {
-					send(ch, Event{ROTEL_FLAG_NONE, fmt.Errorf("writetty: %w", err)})
-				}
-			}
-			timer.Reset(time.Millisecond * 500)
-		default:
-			if err := self.readtty(ch); err != nil {
-				send(ch, Event{ROTEL_FLAG_NONE, fmt.Errorf("readtty: %w", err)})
-			}
-		}
+	var wg sync.WaitGroup
+	var result error
+
+	// Forward events to the channel
+	if ch != nil {
+		self.forward(ctx, ch)
 	}
 
-	// Close RS232 connection
-	var result error
-	if self.fd != nil {
-		if err := self.fd.Close(); err != nil {
-			result = errors.Join(result, err)
-		}
+	// Save the state when it changes
+	if self.file != "" {
+		wg.Add(1)
+		go func(events <-chan Event) {
+			defer wg.Done()
+			result = self.persistEvents(events, deltaPersist)
+		}(self.Subscribe(ctx, ROTEL_FLAG_NONE))
 	}
 
-	// Clear resources
-	self.fd = nil
-	self.buf = nil
+	// Converge towards any desired state
+	wg.Add(1)
+	go func(events <-chan Event) {
+		defer wg.Done()
+		self.reconcile(ctx, events)
+	}(self.Subscribe(ctx, ROTEL_FLAG_NONE))
+
+	// Apply deferred commands once the amplifier is ready
+	if self.deferral.timeout > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			self.flush(ctx)
+		}()
+	}
+
+	// Report any provisional values, including those while in standby, once
+	// the events are subscribed
+	if s := self.LastKnown(); s.Provisional != ROTEL_FLAG_NONE {
+		self.publish(Event{Flag: s.Provisional, Time: time.Now(), New: s})
+	}
 
-	// Return any errors
-	return result
+	// Run the transport, then wait for the state to be saved
+	err := self.transport.run(ctx, &self.state, nil)
+	wg.Wait()
+	return errors.Join(err, result)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
 func (self *Rotel) SetPower(state bool) error {
-	if state {
-		return self.writetty("power_on!")
-	} else {
-		return self.writetty("power_off!")
-	}
+	self.deferral.power(state)
+	return self.send(protocol.SetPower(state))
 }
 
 func (self *Rotel) SetSpeaker(state bool, speaker string) error {
-	// Cannot set value when power is off
-	if !self.Power() {
-		return ErrOutOfOrder.With("SetSpeaker")
-	}
-
-	if state {
-		return self.writetty("speaker_" + speaker + "_on!")
-	} else {
-		return self.writetty("speaker_" + speaker + "_off!")
-	}
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetSpeaker", func() error {
+		return self.send(protocol.SetSpeaker{Speaker: speaker, On: state})
+	})
 }
 
 func (self *Rotel) SetSource(value string) error {
-	// Cannot set value when power is off
-	if !self.Power() {
-		return ErrOutOfOrder.With("SetSource")
-	}
-
-	// Check parameter and send command
-	switch value {
-	case "pc_usb":
-		return self.writetty("pcusb!")
-	case "cd", "coax1", "coax2", "opt1", "opt2", "aux1", "aux2", "tuner", "phono", "usb", "bluetooth":
-		return self.writetty(value + "!")
-	default:
+	// Check parameter
+	if !self.Profile().HasSource(value) {
 		return ErrBadParameter.Withf("invalid source: %q", value)
 	}
+
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetSource", func() error {
+		return self.send(protocol.SetSource(value))
+	})
 }
 
 func (self *Rotel) SetVolume(value uint) error {
-	// Cannot set value when power is off
-	if self.Power() == false {
-		return ErrOutOfOrder.With("SetVolume")
+	// Check parameter
+	if profile := self.Profile(); value < profile.VolumeMin || value > profile.VolumeMax {
+		return ErrOutOfRange.Withf("volume: %d", value)
 	}
 
-	// Check parameter and send command
-	if value < 1 || value > 96 {
-		return ErrBadParameter.With("invalid volume: %d", value)
-	} else {
-		return self.writetty(fmt.Sprintf("vol_%02d!", value))
-	}
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetVolume", func() error {
+		return self.send(protocol.SetVolume(value))
+	})
 }
 
 func (self *Rotel) SetBass(value int) error {
-	// Cannot set value when power is off
-	if self.Power() == false {
-		return ErrOutOfOrder.With("SetBass")
+	// Check parameter
+	cmd := protocol.SetBass(value)
+	if !cmd.Valid() {
+		return ErrOutOfRange.Withf("bass: %d", value)
 	}
 
-	// Check parameter and send command
-	if value < TONE_MIN || value > TONE_MAX {
-		return ErrBadParameter.With("SetBass")
-	} else if value == 0 {
-		return self.writetty("bass_000!")
-	} else if value < 0 {
-		return self.writetty(fmt.Sprint("bass_", value, "!"))
-	} else {
-		return self.writetty(fmt.Sprint("bass_+", value, "!"))
-	}
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetBass", func() error {
+		return self.send(cmd)
+	})
 }
 
 func (self *Rotel) SetTreble(value int) error {
-	// Cannot set value when power is off
-	if self.Power() == false {
-		return ErrOutOfOrder.With("SetTreble")
+	// Check parameter
+	cmd := protocol.SetTreble(value)
+	if !cmd.Valid() {
+		return ErrOutOfRange.Withf("treble: %d", value)
 	}
 
-	// Check parameter and send command
-	if value < TONE_MIN || value > TONE_MAX {
-		return ErrBadParameter.With("SetTreble")
-	} else if value == 0 {
-		return self.writetty("treble_000!")
-	} else if value < 0 {
-		return self.writetty(fmt.Sprint("treble_", value, "!"))
-	} else {
-		return self.writetty(fmt.Sprint("treble_+", value, "!"))
-	}
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetTreble", func() error {
+		return self.send(cmd)
+	})
 }
 
-/*
-func (this *Manager) SetMute(state bool) error {
-	// Cannot set value when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("SetMute")
+func (self *Rotel) SetPhonoMode(value string) error {
+	// Cannot set value when model has no phono mode
+	if !self.Profile().Phono {
+		return ErrUnsupported.With("SetPhonoMode")
 	}
 
-	// Check parameter and send command
-	if state {
-		return this.writetty("mute_on!")
-	} else {
-		return this.writetty("mute_off!")
-	}
-}
-
-func (this *Manager) SetBypass(state bool) error {
-	// Cannot set value when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("SetBypass")
+	// Check parameter
+	if !contains(PHONO_MODES, value) {
+		return ErrBadParameter.Withf("invalid phono mode: %q", value)
 	}
 
-	// Check parameter and send command
-	if state {
-		return this.writetty("bypass_on!")
-	} else {
-		return this.writetty("bypass_off!")
-	}
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetPhonoMode", func() error {
+		return self.send(protocol.SetPhonoMode(value))
+	})
 }
 
-func (this *Manager) SetBalance(loc string) error {
-	// Cannot set value when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("SetBalance")
+func (self *Rotel) SetMode(value string) error {
+	// Cannot set value when model is not a processor
+	if !self.Profile().Processor {
+		return ErrUnsupported.With("SetMode")
 	}
 
-	// Check parameter and send command
-	switch loc {
-	case "0":
-		return this.writetty("balance_000!")
-	case "L", "R":
-		return this.writetty(fmt.Sprintf("balance_%v!", strings.ToLower(loc)))
-	default:
-		return ErrBadParameter.With("SetBalance")
+	// Check parameter
+	if !contains(MODES, value) {
+		return ErrBadParameter.Withf("invalid mode: %q", value)
 	}
-}
 
-func (this *Manager) SetDimmer(value uint) error {
-	// Cannot set value when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("SetDimmer")
-	}
-
-	// Check parameter and send command
-	if value > 6 {
-		return ErrBadParameter.With("SetDimmer")
-	} else {
-		return this.writetty(fmt.Sprint("dimmer_", value, "!"))
-	}
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetMode", func() error {
+		return self.send(protocol.SetMode(value))
+	})
 }
 
-func (this *Manager) Play() error {
-	// Cannot perform action when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("Play")
-	}
+func (self *Rotel) SetMute(state bool) error {
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetMute", func() error {
+		return self.send(protocol.SetMute(state))
+	})
+}
 
-	// Send command
-	return this.writetty("play!")
+func (self *Rotel) SetBypass(state bool) error {
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetBypass", func() error {
+		return self.send(protocol.SetBypass(state))
+	})
 }
 
-func (this *Manager) Stop() error {
-	// Cannot perform action when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("Stop")
+// SetBalance sets the balance, where negative values are to the left and
+// positive values to the right
+func (self *Rotel) SetBalance(value int) error {
+	// Check parameter
+	cmd := protocol.SetBalance(value)
+	if !cmd.Valid() {
+		return ErrOutOfRange.Withf("balance: %d", value)
 	}
 
-	// Send command
-	return this.writetty("stop!")
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetBalance", func() error {
+		return self.send(cmd)
+	})
 }
 
-func (this *Manager) Pause() error {
-	// Cannot perform action when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("Pause")
+func (self *Rotel) SetDimmer(value uint) error {
+	// Check parameter
+	cmd := protocol.SetDimmer(value)
+	if !cmd.Valid() {
+		return ErrOutOfRange.Withf("dimmer: %d", value)
 	}
 
-	// Send command
-	return this.writetty("pause!")
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready("SetDimmer", func() error {
+		return self.send(cmd)
+	})
 }
 
-func (this *Manager) NextTrack() error {
-	// Cannot perform action when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("NextTrack")
-	}
-
-	// Send command
-	return this.writetty("trkf!")
+func (self *Rotel) SetCenter(value int) error {
+	return self.setTrim("center", value, protocol.SetCenter(value))
 }
 
-func (this *Manager) PrevTrack() error {
-	// Cannot perform action when power is off
-	if this.Power() == false {
-		return ErrOutOfOrder.With("PrevTrack")
-	}
+func (self *Rotel) SetSubwoofer(value int) error {
+	return self.setTrim("subwoofer", value, protocol.SetSubwoofer(value))
+}
 
-	// Send command
-	return this.writetty("trkb!")
+func (self *Rotel) SetSurround(value int) error {
+	return self.setTrim("surround", value, protocol.SetSurround(value))
 }
-*/
 
 ////////////////////////////////////////////////////////////////////////////////
 // STRINGIFY
 
 func (self *Rotel) String() string {
 	str := "<rotel"
-	if self.fd != nil {
+	if self.transport != nil && self.fd != nil {
 		str += fmt.Sprintf(" tty=%q", self.fd)
 	}
 	//str += fmt.Sprint(" ", this.State.String())
@@ -355,53 +346,29 @@ func (self *Rotel) String() string {
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHDOS
 
-func (self *Rotel) readtty(ch chan<- Event) error {
-	var result error
-	var flags Flag
-
-	// Append data to the buffer and parse any parameters
-	buf := make([]byte, 1024)
-	if n, err := self.fd.Read(buf); err == io.EOF {
-		return nil
-	} else if err != nil {
-		return err
-	} else if _, err := self.buf.Write(buf[:n]); err != nil {
-		return err
-	} else if fields := strings.Split(self.buf.String(), "$"); len(fields) > 0 {
-		// Parse each field and update state
-		for _, param := range fields[0 : len(fields)-1] {
-			if flag, err := self.state.Set(param); err != nil {
-				result = errors.Join(result, fmt.Errorf("%q: %w", param, err))
-			} else {
-				flags |= flag
-			}
-		}
-		// Reset buffer with any remaining data not parsed
-		self.buf.Reset()
-		self.buf.WriteString(fields[len(fields)-1])
+func (self *Rotel) setTrim(channel string, value int, cmd protocol.Setter) error {
+	// Cannot set value when model is not a processor
+	if !self.Profile().Processor {
+		return ErrUnsupported.With(channel)
 	}
 
-	// If any flags set, then emit an event
-	if flags != ROTEL_FLAG_NONE {
-		if err := send(ch, Event{flags, nil}); err != nil {
-			result = errors.Join(result, err)
-		}
+	// Check parameter
+	if !cmd.Valid() {
+		return ErrOutOfRange.Withf("%s: %d", channel, value)
 	}
 
-	// Return any errors
-	return result
+	// Cannot set value when power is off, unless deferred while powering on
+	return self.ready(channel, func() error {
+		return self.send(cmd)
+	})
 }
 
-func (self *Rotel) writetty(cmd string) error {
-	_, err := self.fd.Write([]byte(cmd))
-	return err
-}
-
-func send(ch chan<- Event, evt Event) error {
-	select {
-	case ch <- evt:
-		return nil
-	default:
-		return ErrChannelBlocked
+// contains returns true if value is one of values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
 }
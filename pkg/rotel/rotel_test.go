@@ -0,0 +1,162 @@
+package rotel
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	// Namespace imports
+	goerrors "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// recorder is a connection which records the commands written to it
+type recorder struct {
+	bytes.Buffer
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Rotel_001(t *testing.T) {
+	// Listening modes and trims are sent to surround processors
+	self, conn := newRecorded(t, "model=RSP-1576", "power=on")
+	tests := []struct {
+		fn  func() error
+		cmd string
+	}{
+		{func() error { return self.SetMode("dolby_pl2_music") }, "dolby_pl2_music!"},
+		{func() error { return self.SetCenter(-3) }, "center_-3!"},
+		{func() error { return self.SetSubwoofer(10) }, "subwoofer_+10!"},
+		{func() error { return self.SetSurround(0) }, "surround_000!"},
+	}
+	for _, test := range tests {
+		conn.Reset()
+		if err := test.fn(); err != nil {
+			t.Error(test.cmd, err)
+		} else if cmd := conn.String(); cmd != test.cmd {
+			t.Errorf("expected %q, got %q", test.cmd, cmd)
+		}
+	}
+}
+
+func Test_Rotel_002(t *testing.T) {
+	// Invalid modes and trims are rejected without sending a command
+	self, conn := newRecorded(t, "model=RSP-1576", "power=on")
+	for _, err := range []error{
+		self.SetMode("dolby_atmos"),
+		self.SetCenter(-11),
+		self.SetSubwoofer(11),
+	} {
+		if !errors.Is(err, goerrors.ErrBadParameter) {
+			t.Error("unexpected error:", err)
+		}
+	}
+	if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+}
+
+func Test_Rotel_003(t *testing.T) {
+	// Modes and trims are not supported by amplifiers, or sent in standby
+	amp, conn := newRecorded(t, "model=A14", "power=on")
+	if err := amp.SetMode("stereo"); !errors.Is(err, ErrUnsupported) {
+		t.Error("unexpected error:", err)
+	}
+	if err := amp.SetCenter(0); !errors.Is(err, ErrUnsupported) {
+		t.Error("unexpected error:", err)
+	}
+	processor, _ := newRecorded(t, "model=RSP-1576", "power=standby")
+	if err := processor.SetSurround(1); !errors.Is(err, ErrPowerOff) {
+		t.Error("unexpected error:", err)
+	}
+	if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+}
+
+func Test_Rotel_004(t *testing.T) {
+	// Sources which are not available on the model are rejected
+	self, conn := newRecorded(t, "model=A14", "power=on")
+	for _, source := range []string{"balanced", "cassette", ""} {
+		if err := self.SetSource(source); !errors.Is(err, goerrors.ErrBadParameter) {
+			t.Errorf("%q: unexpected error: %v", source, err)
+		}
+	}
+	if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+	if err := self.SetSource("pc_usb"); err != nil {
+		t.Error(err)
+	} else if cmd := conn.String(); cmd != "pcusb!" {
+		t.Errorf("expected %q, got %q", "pcusb!", cmd)
+	}
+}
+
+func Test_Rotel_005(t *testing.T) {
+	// Mute, bypass, balance and dimmer are sent when the power is on, and
+	// values out of range are rejected
+	self, conn := newRecorded(t, "model=A14", "power=on")
+	tests := []struct {
+		fn  func() error
+		cmd string
+	}{
+		{func() error { return self.SetMute(true) }, "mute_on!"},
+		{func() error { return self.SetBypass(false) }, "bypass_off!"},
+		{func() error { return self.SetBalance(-3) }, "balance_l03!"},
+		{func() error { return self.SetBalance(0) }, "balance_000!"},
+		{func() error { return self.SetDimmer(6) }, "dimmer_6!"},
+	}
+	for _, test := range tests {
+		conn.Reset()
+		if err := test.fn(); err != nil {
+			t.Error(test.cmd, err)
+		} else if cmd := conn.String(); cmd != test.cmd {
+			t.Errorf("expected %q, got %q", test.cmd, cmd)
+		}
+	}
+
+	conn.Reset()
+	for _, err := range []error{self.SetBalance(16), self.SetDimmer(7)} {
+		if !errors.Is(err, ErrOutOfRange) {
+			t.Error("unexpected error:", err)
+		}
+	}
+	self.state.Set("power=standby")
+	if err := self.SetMute(false); !errors.Is(err, ErrPowerOff) {
+		t.Error("unexpected error:", err)
+	}
+	if conn.Len() != 0 {
+		t.Errorf("unexpected command %q", conn.String())
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// newRecorded returns a driver with the state set from responses, which
+// writes commands to a recorder
+func newRecorded(t *testing.T, params ...string) (*Rotel, *recorder) {
+	t.Helper()
+	conn := new(recorder)
+	self, err := NewWithConn(Config{}, func() (io.ReadWriteCloser, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, param := range params {
+		if _, err := self.state.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	return self, conn
+}
+
+// Close closes the connection
+func (r *recorder) Close() error {
+	return nil
+}
@@ -0,0 +1,318 @@
+package rotel
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	// Packages
+	term "github.com/pkg/term"
+	termios "github.com/pkg/term/termios"
+	unix "golang.org/x/sys/unix"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Parity is the parity bit of the serial line
+type Parity uint
+
+// Flow is the flow control of the serial line
+type Flow uint
+
+// Signal is the state of a modem control line (DTR or RTS) set when the
+// serial port is opened
+type Signal uint
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	PARITY_NONE Parity = iota
+	PARITY_ODD
+	PARITY_EVEN
+)
+
+const (
+	FLOW_NONE     Flow = iota
+	FLOW_SOFTWARE      // XON/XOFF
+	FLOW_HARDWARE      // RTS/CTS
+)
+
+const (
+	SIGNAL_DEFAULT Signal = iota // Left as set by the driver
+	SIGNAL_ON
+	SIGNAL_OFF
+)
+
+const (
+	DEFAULT_TTY_DATABITS = 8
+	DEFAULT_TTY_STOPBITS = 1
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// openSerial opens the serial port with the line settings in the
+// configuration, which should already have been checked
+func openSerial(cfg Config) (io.ReadWriteCloser, error) {
+	fd, err := term.Open(cfg.TTY, term.Speed(int(cfg.Baud)), term.RawMode, term.FlowControl(cfg.Flow.kind()))
+	if err != nil {
+		return nil, err
+	}
+	if err := setLine(cfg); err != nil {
+		defer fd.Close()
+		return nil, err
+	}
+	if cfg.DTR != SIGNAL_DEFAULT {
+		if err := fd.SetDTR(cfg.DTR == SIGNAL_ON); err != nil {
+			defer fd.Close()
+			return nil, err
+		}
+	}
+	if cfg.RTS != SIGNAL_DEFAULT {
+		if err := fd.SetRTS(cfg.RTS == SIGNAL_ON); err != nil {
+			defer fd.Close()
+			return nil, err
+		}
+	}
+	if err := fd.SetReadTimeout(cfg.Timeout); err != nil {
+		defer fd.Close()
+		return nil, err
+	}
+	return fd, nil
+}
+
+// isSerial returns true if the connection is a serial port
+func isSerial(fd io.ReadWriteCloser) bool {
+	_, ok := fd.(*term.Term)
+	return ok
+}
+
+// checkSerial sets the default line settings and checks them
+func checkSerial(cfg *Config) error {
+	if cfg.DataBits == 0 {
+		cfg.DataBits = DEFAULT_TTY_DATABITS
+	}
+	if cfg.StopBits == 0 {
+		cfg.StopBits = DEFAULT_TTY_STOPBITS
+	}
+	if cfg.DataBits < 5 || cfg.DataBits > 8 {
+		return ErrBadParameter.Withf("data bits: %d", cfg.DataBits)
+	}
+	if cfg.StopBits > 2 {
+		return ErrBadParameter.Withf("stop bits: %d", cfg.StopBits)
+	}
+	if cfg.Flow == FLOW_HARDWARE && cfg.RTS != SIGNAL_DEFAULT {
+		return ErrBadParameter.With("rts: cannot be set with hardware flow control")
+	}
+	return nil
+}
+
+// ParseParity returns the parity from its name: none, odd or even
+func ParseParity(value string) (Parity, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "none", "":
+		return PARITY_NONE, nil
+	case "odd":
+		return PARITY_ODD, nil
+	case "even":
+		return PARITY_EVEN, nil
+	default:
+		return PARITY_NONE, ErrBadParameter.Withf("parity: %q", value)
+	}
+}
+
+// ParseFlow returns the flow control from its name: none, software
+// (or xonxoff) or hardware (or rtscts)
+func ParseFlow(value string) (Flow, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "none", "":
+		return FLOW_NONE, nil
+	case "software", "xonxoff":
+		return FLOW_SOFTWARE, nil
+	case "hardware", "rtscts":
+		return FLOW_HARDWARE, nil
+	default:
+		return FLOW_NONE, ErrBadParameter.Withf("flow control: %q", value)
+	}
+}
+
+// ParseSignal returns the state of a modem control line: on, off or
+// default to leave it unchanged
+func ParseSignal(value string) (Signal, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "default", "":
+		return SIGNAL_DEFAULT, nil
+	case "on", "true":
+		return SIGNAL_ON, nil
+	case "off", "false":
+		return SIGNAL_OFF, nil
+	default:
+		return SIGNAL_DEFAULT, ErrBadParameter.Withf("signal: %q", value)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (p Parity) String() string {
+	switch p {
+	case PARITY_NONE:
+		return "none"
+	case PARITY_ODD:
+		return "odd"
+	case PARITY_EVEN:
+		return "even"
+	default:
+		return "[?? Invalid Parity value]"
+	}
+}
+
+func (f Flow) String() string {
+	switch f {
+	case FLOW_NONE:
+		return "none"
+	case FLOW_SOFTWARE:
+		return "software"
+	case FLOW_HARDWARE:
+		return "hardware"
+	default:
+		return "[?? Invalid Flow value]"
+	}
+}
+
+func (s Signal) String() string {
+	switch s {
+	case SIGNAL_DEFAULT:
+		return "default"
+	case SIGNAL_ON:
+		return "on"
+	case SIGNAL_OFF:
+		return "off"
+	default:
+		return "[?? Invalid Signal value]"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// MarshalText returns the parity name
+func (p Parity) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText sets the parity from its name
+func (p *Parity) UnmarshalText(data []byte) error {
+	if v, err := ParseParity(string(data)); err != nil {
+		return err
+	} else {
+		*p = v
+	}
+	return nil
+}
+
+// Set sets the parity from a command-line flag
+func (p *Parity) Set(value string) error {
+	return p.UnmarshalText([]byte(value))
+}
+
+// MarshalText returns the flow control name
+func (f Flow) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText sets the flow control from its name
+func (f *Flow) UnmarshalText(data []byte) error {
+	if v, err := ParseFlow(string(data)); err != nil {
+		return err
+	} else {
+		*f = v
+	}
+	return nil
+}
+
+// Set sets the flow control from a command-line flag
+func (f *Flow) Set(value string) error {
+	return f.UnmarshalText([]byte(value))
+}
+
+// MarshalText returns the signal state
+func (s Signal) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets the signal state from its name
+func (s *Signal) UnmarshalText(data []byte) error {
+	if v, err := ParseSignal(string(data)); err != nil {
+		return err
+	} else {
+		*s = v
+	}
+	return nil
+}
+
+// Set sets the signal state from a command-line flag
+func (s *Signal) Set(value string) error {
+	return s.UnmarshalText([]byte(value))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// setLine sets the parity, data bits and stop bits of the serial port.
+// The term package does not expose its file descriptor, so the port is
+// opened again, and the settings apply to both
+func setLine(cfg Config) error {
+	fd, err := os.OpenFile(cfg.TTY, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	var attr unix.Termios
+	if err := termios.Tcgetattr(fd.Fd(), &attr); err != nil {
+		return err
+	}
+	attr.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB
+	attr.Iflag &^= unix.INPCK
+	switch cfg.DataBits {
+	case 5:
+		attr.Cflag |= unix.CS5
+	case 6:
+		attr.Cflag |= unix.CS6
+	case 7:
+		attr.Cflag |= unix.CS7
+	default:
+		attr.Cflag |= unix.CS8
+	}
+	switch cfg.Parity {
+	case PARITY_ODD:
+		attr.Cflag |= unix.PARENB | unix.PARODD
+		attr.Iflag |= unix.INPCK
+	case PARITY_EVEN:
+		attr.Cflag |= unix.PARENB
+		attr.Iflag |= unix.INPCK
+	}
+	if cfg.StopBits == 2 {
+		attr.Cflag |= unix.CSTOPB
+	}
+	return termios.Tcsetattr(fd.Fd(), termios.TCSANOW, &attr)
+}
+
+// kind returns the flow control for the term package
+func (f Flow) kind() int {
+	switch f {
+	case FLOW_SOFTWARE:
+		return term.XONXOFF
+	case FLOW_HARDWARE:
+		return term.HARDWARE
+	default:
+		return term.NONE
+	}
+}
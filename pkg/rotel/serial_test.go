@@ -0,0 +1,118 @@
+package rotel_test
+
+import (
+	"context"
+	"testing"
+
+	// Package imports
+	rotel "github.com/djthorpe/go-rotel/pkg/rotel"
+	termios "github.com/pkg/term/termios"
+	unix "golang.org/x/sys/unix"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Serial_001(t *testing.T) {
+	// Line settings are parsed from their names
+	for _, parity := range []rotel.Parity{rotel.PARITY_NONE, rotel.PARITY_ODD, rotel.PARITY_EVEN} {
+		var v rotel.Parity
+		if err := v.UnmarshalText([]byte(parity.String())); err != nil {
+			t.Error(err)
+		} else if v != parity {
+			t.Errorf("expected %v, got %v", parity, v)
+		}
+	}
+	for _, flow := range []rotel.Flow{rotel.FLOW_NONE, rotel.FLOW_SOFTWARE, rotel.FLOW_HARDWARE} {
+		var v rotel.Flow
+		if err := v.UnmarshalText([]byte(flow.String())); err != nil {
+			t.Error(err)
+		} else if v != flow {
+			t.Errorf("expected %v, got %v", flow, v)
+		}
+	}
+	for _, signal := range []rotel.Signal{rotel.SIGNAL_DEFAULT, rotel.SIGNAL_ON, rotel.SIGNAL_OFF} {
+		var v rotel.Signal
+		if err := v.UnmarshalText([]byte(signal.String())); err != nil {
+			t.Error(err)
+		} else if v != signal {
+			t.Errorf("expected %v, got %v", signal, v)
+		}
+	}
+	if _, err := rotel.ParseParity("mark"); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := rotel.ParseFlow("dsrdtr"); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := rotel.ParseSignal("toggle"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func Test_Serial_002(t *testing.T) {
+	// Line settings are applied when the port is opened
+	ptm, pts, err := termios.Pty()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ptm.Close()
+	defer pts.Close()
+
+	driver, err := rotel.NewWithConfig(rotel.Config{
+		TTY:      pts.Name(),
+		Baud:     9600,
+		Parity:   rotel.PARITY_EVEN,
+		DataBits: 7,
+		StopBits: 2,
+		Flow:     rotel.FLOW_SOFTWARE,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop(driver)
+
+	var attr unix.Termios
+	if err := termios.Tcgetattr(pts.Fd(), &attr); err != nil {
+		t.Fatal(err)
+	}
+	// Pseudo-terminals always use 8 data bits without parity, so only the
+	// stop bits and flow control can be checked
+	if attr.Cflag&unix.CSTOPB == 0 {
+		t.Error("expected 2 stop bits")
+	}
+	if attr.Iflag&unix.IXON == 0 {
+		t.Error("expected software flow control")
+	}
+}
+
+func Test_Serial_003(t *testing.T) {
+	// Invalid line settings are rejected
+	ptm, pts, err := termios.Pty()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ptm.Close()
+	defer pts.Close()
+
+	for _, cfg := range []rotel.Config{
+		{TTY: pts.Name(), DataBits: 9},
+		{TTY: pts.Name(), StopBits: 3},
+		{TTY: pts.Name(), Flow: rotel.FLOW_HARDWARE, RTS: rotel.SIGNAL_ON},
+	} {
+		if driver, err := rotel.NewWithConfig(cfg); err == nil {
+			stop(driver)
+			t.Errorf("%+v: expected an error", cfg)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// stop runs the driver with a cancelled context, which closes the port
+func stop(driver *rotel.Rotel) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	driver.Run(ctx, nil)
+}
@@ -0,0 +1,174 @@
+package rotel
+
+import (
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// State is a snapshot of the amplifier state. When the power is off, only
+// the model and power fields are set, unless the snapshot is returned by
+// LastKnown. LastKnown also returns values loaded from a state file which
+// the amplifier has not yet reported, flagged in Provisional
+type State struct {
+	Model     string `json:"model"`
+	Power     bool   `json:"power"`
+	Volume    uint   `json:"volume"`
+	Mute      bool   `json:"mute"`
+	Bass      int    `json:"bass"`
+	Treble    int    `json:"treble"`
+	Balance   int    `json:"balance"` // Negative values are to the left, positive to the right
+	Source    string `json:"source"`
+	Freq      string `json:"freq"`
+	Bypass    bool   `json:"bypass"`
+	SpeakerA  bool   `json:"speaker_a"`
+	SpeakerB  bool   `json:"speaker_b"`
+	Dimmer    uint   `json:"dimmer"`
+	PhonoMode string `json:"phono_mode"`
+
+	// Surround processors
+	Mode      string `json:"mode"`
+	Format    string `json:"format"`
+	Center    int    `json:"center"`
+	Subwoofer int    `json:"subwoofer"`
+	Surround  int    `json:"surround"`
+
+	// Values not yet confirmed by the amplifier
+	Provisional Flag `json:"provisional,omitempty"`
+
+	// Last known values while the power is off or the device is offline,
+	// which may have changed since
+	Stale Flag `json:"stale,omitempty"`
+
+	// Device has stopped responding
+	Offline bool `json:"offline"`
+}
+
+// Snapshot is the state of a device at a point in time, which is a State
+// for amplifiers, a CDState for CD players and a TunerState for tuners
+type Snapshot interface {
+	isSnapshot()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// State returns a consistent snapshot of the amplifier state
+func (this *state) State() State {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.snapshot(false)
+}
+
+// LastKnown returns a snapshot of the amplifier state which includes the
+// last known values while the power is off. These values are flagged
+// in Stale
+func (this *state) LastKnown() State {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.snapshot(true)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// snapshot returns the state, and should be called with the lock held. If
+// all is true, values are returned when the power is off, and provisional
+// values are returned for values which are not yet known
+func (this *state) snapshot(all bool) State {
+	var s State
+	var known Flag
+	s.Offline = this.offline
+
+	// Only the last known state uses provisional values, so that commands
+	// are checked against the confirmed state
+	p := this.provisional
+	if p == nil || !all {
+		p = new(persisted)
+	}
+	value := func(value, provisional string, flag Flag) string {
+		if value == "" && provisional != "" {
+			s.Provisional |= flag
+			value = provisional
+		}
+		if value != "" {
+			known |= flag
+		}
+		return value
+	}
+
+	s.Model = value(this.model, p.Model, ROTEL_FLAG_MODEL)
+	s.Power = value(this.power, p.Power, ROTEL_FLAG_POWER) == "on"
+
+	// When power is off, don't return other values
+	if !s.Power && !all {
+		if s.Offline {
+			s.Stale = known
+		}
+		return s
+	}
+
+	s.Volume = parseUint(value(this.volume, p.Volume, ROTEL_FLAG_VOLUME))
+	s.Mute = value(this.mute, p.Mute, ROTEL_FLAG_MUTE) == "on"
+	s.Bass = parseInt(value(this.bass, p.Bass, ROTEL_FLAG_BASS))
+	s.Treble = parseInt(value(this.treble, p.Treble, ROTEL_FLAG_TREBLE))
+	balance := this.balance
+	if balance == nil && len(p.Balance) == 2 {
+		balance = p.Balance
+		s.Provisional |= ROTEL_FLAG_BALANCE
+	}
+	if balance != nil {
+		known |= ROTEL_FLAG_BALANCE
+		switch balance[0] {
+		case "L":
+			s.Balance = -int(parseUint(balance[1]))
+		case "R":
+			s.Balance = int(parseUint(balance[1]))
+		}
+	}
+	s.Source = value(this.source, p.Source, ROTEL_FLAG_SOURCE)
+	if freq := value(this.freq, p.Freq, ROTEL_FLAG_FREQ); freq != "off" {
+		s.Freq = freq
+	}
+	s.Bypass = value(this.bypass, p.Bypass, ROTEL_FLAG_BYPASS) == "on"
+	speaker := value(this.speaker, p.Speaker, ROTEL_FLAG_SPEAKER)
+	s.SpeakerA = speaker == "a" || speaker == "a_b"
+	s.SpeakerB = speaker == "b" || speaker == "a_b"
+	s.Dimmer = parseUint(value(this.dimmer, p.Dimmer, ROTEL_FLAG_DIMMER))
+	s.PhonoMode = value(this.phono, p.Phono, ROTEL_FLAG_PHONO)
+	s.Mode = value(this.mode, p.Mode, ROTEL_FLAG_MODE)
+	s.Format = value(this.format, p.Format, ROTEL_FLAG_FORMAT)
+	s.Center = parseInt(value(this.center, p.Center, ROTEL_FLAG_CENTER))
+	s.Subwoofer = parseInt(value(this.subwoofer, p.Subwoofer, ROTEL_FLAG_SUBWOOFER))
+	s.Surround = parseInt(value(this.surround, p.Surround, ROTEL_FLAG_SURROUND))
+
+	// When offline, all values are stale. When power is off, all values
+	// other than model and power are stale
+	if s.Offline {
+		s.Stale = known
+	} else if !s.Power {
+		s.Stale = known &^ (ROTEL_FLAG_MODEL | ROTEL_FLAG_POWER)
+	}
+
+	// Return the snapshot
+	return s
+}
+
+func parseUint(value string) uint {
+	if v, err := strconv.ParseUint(value, 0, 32); err == nil {
+		return uint(v)
+	}
+	return 0
+}
+
+func parseInt(value string) int {
+	if v, err := strconv.ParseInt(value, 0, 32); err == nil {
+		return int(v)
+	}
+	return 0
+}
+
+func (State) isSnapshot()      {}
+func (CDState) isSnapshot()    {}
+func (TunerState) isSnapshot() {}
@@ -2,8 +2,12 @@ package rotel
 
 import (
 	"fmt"
-	"regexp"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
 
 	// Modules
 	. "github.com/djthorpe/go-errors"
@@ -13,9 +17,13 @@ import (
 // TYPES
 
 type state struct {
+	mu            sync.RWMutex
 	model         string
 	power         string
 	update        string // rs232 update
+	original      string // rs232 update when first reported, restored on shutdown
+	requested     bool   // rs232_update_on! sent on this connection
+	strategy      Strategy
 	volume, mute  string
 	bass, treble  string
 	balance       []string
@@ -24,154 +32,126 @@ type state struct {
 	bypass        string
 	speaker       string
 	dimmer        string
+	phono         string // Phono MM/MC mode
 	volume_update bool
-}
+	powered       time.Time // Time power was switched on
 
-////////////////////////////////////////////////////////////////////////////////
-// CONSTANTS
-
-var (
-	commands = []struct {
-		re *regexp.Regexp
-		fn func(this *state, args []string) (Flag, error)
-	}{
-		{regexp.MustCompile("^model=(\\w+)$"), SetModel},
-		{regexp.MustCompile("^power=(on|standby)$"), SetPower},
-		{regexp.MustCompile("^volume=(\\d+)$"), SetVolume},
-		{regexp.MustCompile("^update_mode=(auto|manual)$"), SetUpdateMode},
-		{regexp.MustCompile("^bass=([\\+\\-]?\\d+)$"), SetBass},
-		{regexp.MustCompile("^treble=([\\+\\-]?\\d+)$"), SetTreble},
-		{regexp.MustCompile("^balance=([LR]?)(\\d+)$"), SetBalance},
-		{regexp.MustCompile("^mute=(on|off)$"), SetMute},
-		{regexp.MustCompile("^source=(\\w+)$"), SetSource},
-		{regexp.MustCompile("^freq=(.+)$"), SetFreq},
-		{regexp.MustCompile("^bypass=(on|off)$"), SetBypass},
-		{regexp.MustCompile("^speaker=(a|b|a_b|off)$"), SetSpeaker},
-		{regexp.MustCompile("^dimmer=(\\d+)$"), SetDimmer},
-	}
-)
+	// Surround processors
+	mode, format                string
+	center, subwoofer, surround string
+
+	// Last known values loaded from disk
+	provisional *persisted
+
+	// Liveness
+	offline bool     // Device has stopped responding
+	requery []string // Queries to send during a resync
+}
 
 ////////////////////////////////////////////////////////////////////////////////
 // PROPERTIES
 
 func (this *state) Model() string {
-	return this.model
+	return this.State().Model
+}
+
+func (this *state) Profile() Profile {
+	return ProfileForModel(this.Model())
 }
 
 func (this *state) Power() bool {
-	return this.power == "on"
+	return this.State().Power
 }
 
 func (this *state) Volume() uint {
-	if this.power == "on" {
-		if vol, err := strconv.ParseUint(this.volume, 0, 32); err == nil {
-			return uint(vol)
-		}
-	}
-	return 0
+	return this.State().Volume
 }
 
 func (this *state) Bass() int {
-	if this.power == "on" {
-		if bass, err := strconv.ParseInt(this.bass, 0, 32); err == nil {
-			return int(bass)
-		}
-	}
-	return 0
+	return this.State().Bass
 }
 
 func (this *state) Treble() int {
-	if this.power == "on" {
-		if treble, err := strconv.ParseInt(this.treble, 0, 32); err == nil {
-			return int(treble)
-		}
-	}
-	return 0
+	return this.State().Treble
 }
 
 func (this *state) Balance() (string, uint) {
-	if this.power == "on" && this.balance != nil {
-		if scalar, err := strconv.ParseUint(this.balance[1], 0, 32); err == nil {
-			return this.balance[0], uint(scalar)
-		}
+	if balance := this.State().Balance; balance < 0 {
+		return "L", uint(-balance)
+	} else if balance > 0 {
+		return "R", uint(balance)
 	}
 	return "", 0
 }
 
 func (this *state) Dimmer() uint {
-	if this.power == "on" {
-		if dimmer, err := strconv.ParseUint(this.dimmer, 0, 32); err == nil {
-			return uint(dimmer)
-		}
-	}
-	return 0
+	return this.State().Dimmer
 }
 
 func (this *state) Muted() bool {
-	if this.power == "on" && this.mute == "on" {
-		return true
-	} else {
-		return false
-	}
+	return this.State().Mute
 }
 
 func (this *state) Bypass() bool {
-	if this.power == "on" && this.bypass == "on" {
-		return true
-	} else {
-		return false
-	}
+	return this.State().Bypass
 }
 
 func (this *state) Source() string {
-	if this.power == "on" {
-		return this.source
-	} else {
-		return ""
-	}
+	return this.State().Source
 }
 
 func (this *state) Freq() string {
-	if this.power == "on" && this.freq != "off" {
-		return this.freq
-	} else {
-		return ""
-	}
+	return this.State().Freq
 }
 
+// Speakers returns a, b, a_b or off, or an empty string when the power
+// is off
 func (this *state) Speakers() string {
-	if this.power == "on" {
-		return this.speaker
+	switch s := this.State(); {
+	case !s.Power:
+		return ""
+	case s.SpeakerA && s.SpeakerB:
+		return "a_b"
+	case s.SpeakerA:
+		return "a"
+	case s.SpeakerB:
+		return "b"
+	default:
+		return "off"
 	}
-	return ""
 }
 
 func (this *state) SpeakerA() bool {
-	if this.power == "on" {
-		switch this.speaker {
-		case "a":
-			return true
-		case "b":
-			return false
-		case "a_b":
-			return true
-		}
-	}
-	return false
+	return this.State().SpeakerA
 }
 
 func (this *state) SpeakerB() bool {
-	if this.power == "on" {
-		switch this.speaker {
-		case "a":
-			return false
-		case "b":
-			return true
-		case "a_b":
-			return true
-		}
-	}
-	return false
+	return this.State().SpeakerB
+}
+
+// PhonoMode returns mm or mc on models with a phono mode
+func (this *state) PhonoMode() string {
+	return this.State().PhonoMode
+}
+
+func (this *state) Mode() string {
+	return this.State().Mode
+}
+
+func (this *state) Format() string {
+	return this.State().Format
+}
+
+func (this *state) Center() int {
+	return this.State().Center
+}
+
+func (this *state) Subwoofer() int {
+	return this.State().Subwoofer
+}
+
+func (this *state) Surround() int {
+	return this.State().Surround
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -179,18 +159,68 @@ func (this *state) SpeakerB() bool {
 
 // Update returns a query to get state of an unknown value
 func (this *state) Update(force bool) string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
 	switch {
 	case this.model == "":
 		return "model?"
 	case this.power == "" || force:
 		return "power?"
-	case this.power != "on": // When power is off, don't read other values
-		return ""
+	}
+
+	// When power is off, don't read other values
+	if this.power == "on" {
+		if cmd := this.unknown(); cmd != "" {
+			return cmd
+		}
+	}
+
+	// Re-query known values during a resync
+	if len(this.requery) > 0 {
+		return this.requery[0]
+	}
+
+	// By default, no state needs read
+	return ""
+}
+
+// Set sets state from data coming from amp
+func (this *state) Set(param string) (Flag, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// Remove a resync query when answered
+	if len(this.requery) > 0 && strings.HasPrefix(param, strings.TrimSuffix(this.requery[0], "?")+"=") {
+		this.requery = this.requery[1:]
+	}
+
+	// Decode the response
+	response, err := protocol.Decode(param)
+	if err != nil {
+		return 0, err
+	}
+
+	// Set the state
+	return this.set(response)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// unknown returns a query for the first unknown value when the power is
+// on, or an empty string. Updates are requested once per connection, as
+// some firmware ignores the request. It should be called with the lock held
+func (this *state) unknown() string {
+	switch {
 	case this.volume == "" || this.volume == "0" || this.volume_update:
 		return "volume?"
 	case this.source == "":
 		return "source?"
 	case this.update == "":
+		return "update_mode?"
+	case this.strategy != STRATEGY_POLL && this.update != "auto" && !this.requested:
+		this.requested = true
 		return "rs232_update_on!"
 	case this.freq == "":
 		return "freq?"
@@ -208,148 +238,107 @@ func (this *state) Update(force bool) string {
 		return "balance?"
 	case this.dimmer == "":
 		return "dimmer?"
+	case this.phono == "" && ProfileForModel(this.model).Phono:
+		return "phono_mode?"
 	}
 
-	// By default, no state needs read
-	return ""
-}
-
-// Set sets state from data coming from amp
-func (this *state) Set(param string) (Flag, error) {
-	for _, command := range commands {
-		if args := command.re.FindStringSubmatch(param); len(args) != 0 {
-			return command.fn(this, args[1:])
-		}
+	// Only processors have modes and trims
+	if !ProfileForModel(this.model).Processor {
+		return ""
+	}
+	switch {
+	case this.mode == "":
+		return "mode?"
+	case this.format == "":
+		return "format?"
+	case this.center == "":
+		return "center?"
+	case this.subwoofer == "":
+		return "subwoofer?"
+	case this.surround == "":
+		return "surround?"
 	}
-	// Cannot match command
-	return 0, ErrUnexpectedResponse.With(strconv.Quote(param))
+
+	// All values are known
+	return ""
 }
 
-func SetModel(this *state, args []string) (Flag, error) {
-	if args[0] == "" {
+func (this *state) setModel(model protocol.Model) (Flag, error) {
+	if model == "" {
 		return 0, ErrBadParameter.With("SetModel")
-	} else if this.model != args[0] {
-		this.model = args[0]
+	} else if this.model != string(model) {
+		this.model = string(model)
 		return ROTEL_FLAG_MODEL, nil
 	}
 	return 0, nil
 }
 
-func SetPower(this *state, args []string) (Flag, error) {
-	if args[0] == "" {
-		return 0, ErrBadParameter.With("SetPower")
-	} else if this.power == args[0] {
+func (this *state) setPower(power protocol.Power) (Flag, error) {
+	if this.power == value(power) {
 		return 0, nil
 	}
-	this.power = args[0]
+	previous := this.power
+	this.power = value(power)
 
-	// If the power is switched on, then update the volume
+	// If the power is switched on, then update the volume, otherwise
+	// only the model and power are re-queried. The amplifier only warms
+	// up when switched on, not when the power is first read
 	if this.power == "on" {
 		this.volume_update = true
+		if previous != "" {
+			this.powered = time.Now()
+		}
+	} else {
+		requery := this.requery[:0]
+		for _, query := range this.requery {
+			if query == "model?" || query == "power?" {
+				requery = append(requery, query)
+			}
+		}
+		this.requery = requery
 	}
 
 	// Return the power changed flag
 	return ROTEL_FLAG_POWER, nil
 }
 
-func SetUpdateMode(this *state, args []string) (Flag, error) {
-	if args[0] == "" {
-		return 0, ErrBadParameter.With("SetUpdateMode")
+func (this *state) setUpdateMode(mode protocol.UpdateMode) (Flag, error) {
+	if this.original == "" {
+		this.original = value(mode)
 	}
-	this.update = args[0]
-	return 0, nil
+	return set(&this.update, value(mode), ROTEL_FLAG_NONE)
 }
 
-func SetVolume(this *state, args []string) (Flag, error) {
+func (this *state) setVolume(volume protocol.Volume) (Flag, error) {
 	this.volume_update = false
-	if volume, err := strconv.ParseUint(args[0], 10, 32); err != nil {
-		return 0, err
-	} else if volume_ := fmt.Sprint(volume); volume_ != this.volume {
-		this.volume = volume_
-		return ROTEL_FLAG_VOLUME, nil
-	}
-	return 0, nil
+	return set(&this.volume, fmt.Sprint(uint(volume)), ROTEL_FLAG_VOLUME)
 }
 
-func SetBass(this *state, args []string) (Flag, error) {
-	if bass, err := strconv.ParseInt(args[0], 10, 32); err != nil {
-		return 0, err
-	} else if bass_ := fmt.Sprint(bass); bass_ != this.bass {
-		this.bass = bass_
-		return ROTEL_FLAG_BASS, nil
+func (this *state) setBalance(balance protocol.Balance) (Flag, error) {
+	args := []string{"", fmt.Sprint(int(balance))}
+	if balance < 0 {
+		args = []string{"L", fmt.Sprint(-int(balance))}
+	} else if balance > 0 {
+		args = []string{"R", fmt.Sprint(int(balance))}
 	}
-	return 0, nil
-}
-
-func SetTreble(this *state, args []string) (Flag, error) {
-	if treble, err := strconv.ParseInt(args[0], 10, 32); err != nil {
-		return 0, err
-	} else if treble_ := fmt.Sprint(treble); treble_ != this.treble {
-		this.treble = treble_
-		return ROTEL_FLAG_TREBLE, nil
+	if this.balance == nil || args[0] != this.balance[0] || args[1] != this.balance[1] {
+		this.balance = args
+		return ROTEL_FLAG_BALANCE, nil
 	}
 	return 0, nil
 }
 
-func SetBalance(this *state, args []string) (Flag, error) {
-	if scalar, err := strconv.ParseUint(args[1], 10, 32); err != nil {
-		return 0, err
-	} else {
-		scalar_ := fmt.Sprint(scalar)
-		if this.balance == nil || scalar_ != this.balance[1] || args[0] != this.balance[0] {
-			this.balance = []string{args[0], fmt.Sprint(scalar)}
-			return ROTEL_FLAG_BALANCE, nil
-		}
-	}
-	return 0, nil
-}
-
-func SetMute(this *state, args []string) (Flag, error) {
-	if args[0] != this.mute {
-		this.mute = args[0]
-		return ROTEL_FLAG_MUTE, nil
+// set sets a value and returns the flag when it has changed
+func set(value *string, arg string, flag Flag) (Flag, error) {
+	if *value != arg {
+		*value = arg
+		return flag, nil
 	}
 	return 0, nil
 }
 
-func SetSource(this *state, args []string) (Flag, error) {
-	if args[0] != this.source {
-		this.source = args[0]
-		return ROTEL_FLAG_SOURCE, nil
-	}
-	return 0, nil
-}
-
-func SetFreq(this *state, args []string) (Flag, error) {
-	if args[0] != this.freq {
-		this.freq = args[0]
-		return ROTEL_FLAG_FREQ, nil
-	}
-	return 0, nil
-}
-
-func SetBypass(this *state, args []string) (Flag, error) {
-	if args[0] != this.bypass {
-		this.bypass = args[0]
-		return ROTEL_FLAG_BYPASS, nil
-	}
-	return 0, nil
-}
-
-func SetSpeaker(this *state, args []string) (Flag, error) {
-	if args[0] != this.speaker {
-		this.speaker = args[0]
-		return ROTEL_FLAG_SPEAKER, nil
-	}
-	return 0, nil
-}
-
-func SetDimmer(this *state, args []string) (Flag, error) {
-	if dimmer, err := strconv.ParseUint(args[0], 10, 32); err != nil {
-		return 0, err
-	} else if dimmer_ := fmt.Sprint(dimmer); this.dimmer != dimmer_ {
-		this.dimmer = dimmer_
-		return ROTEL_FLAG_DIMMER, nil
-	}
-	return 0, nil
+// value returns the value of a response as sent by the device, for
+// example "standby" or "a_b"
+func value(r protocol.Response) string {
+	return strings.TrimSuffix(strings.TrimPrefix(string(r.Encode()), r.Key()+"="), "$")
 }
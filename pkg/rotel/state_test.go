@@ -0,0 +1,197 @@
+package rotel
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// FUZZ TESTS
+
+func Fuzz_State_Set(f *testing.F) {
+	for _, param := range []string{
+		"model=A14", "model=RSP-1576", "power=on", "power=standby", "volume=20", "volume=120",
+		"bass=+05", "treble=-10", "balance=L03", "balance=000", "speaker=a_b", "dimmer=6",
+		"mode=stereo", "format=PCM 2.0", "center=+02", "freq=44.1", "update_mode=auto",
+	} {
+		f.Add("power=on", param)
+	}
+	f.Fuzz(func(t *testing.T, first, param string) {
+		this := new(state)
+		this.Set("model=A14")
+		this.Set(first)
+
+		// Setting the same value twice does not report a change
+		flag, err := this.Set(param)
+		if err == nil {
+			if again, err := this.Set(param); err != nil {
+				t.Errorf("%q: unexpected error: %v", param, err)
+			} else if again != ROTEL_FLAG_NONE {
+				t.Errorf("%q: unexpected flags %v after %v", param, again, flag)
+			}
+		} else if flag != ROTEL_FLAG_NONE {
+			t.Errorf("%q: unexpected flags %v with error", param, flag)
+		}
+
+		// The state can be read
+		this.Update(false)
+		this.State()
+		this.LastKnown()
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_State_001(t *testing.T) {
+	// Unknown values are queried in order, and only when the power is on
+	responses := map[string]string{
+		"model?": "model=A14", "power?": "power=on", "volume?": "volume=20", "source?": "source=cd",
+		"update_mode?": "update_mode=auto", "freq?": "freq=off", "bypass?": "bypass=off",
+		"speaker?": "speaker=a", "mute?": "mute=off", "bass?": "bass=000", "treble?": "treble=000",
+		"balance?": "balance=000", "dimmer?": "dimmer=0", "mode?": "mode=stereo", "format?": "format=pcm",
+		"center?": "center=000", "subwoofer?": "subwoofer=000", "surround?": "surround=000",
+	}
+	for _, test := range []struct {
+		model, power string
+		queries      []string
+	}{
+		{"A14", "standby", []string{"model?", "power?"}},
+		{"A14", "on", []string{"model?", "power?", "volume?", "source?", "update_mode?", "freq?", "bypass?", "speaker?", "mute?", "bass?", "treble?", "balance?", "dimmer?"}},
+		{"RSP-1576", "on", []string{"model?", "power?", "volume?", "source?", "update_mode?", "freq?", "bypass?", "speaker?", "mute?", "bass?", "treble?", "balance?", "dimmer?", "mode?", "format?", "center?", "subwoofer?", "surround?"}},
+	} {
+		this := new(state)
+		responses["model?"] = "model=" + test.model
+		responses["power?"] = "power=" + test.power
+		queries := []string{}
+		for query := this.Update(false); query != ""; query = this.Update(false) {
+			if len(queries) > len(responses) {
+				t.Fatal("too many queries", queries)
+			}
+			queries = append(queries, query)
+			if _, err := this.Set(responses[query]); err != nil {
+				t.Fatal(query, err)
+			}
+		}
+		if strings.Join(queries, " ") != strings.Join(test.queries, " ") {
+			t.Errorf("%s %s: unexpected queries %q", test.model, test.power, queries)
+		}
+	}
+}
+
+func Test_State_002(t *testing.T) {
+	// A resync stops at the model and power when the power leaves on
+	this := new(state)
+	for _, param := range []string{"model=A14", "power=on", "volume=20", "source=cd", "update_mode=auto", "freq=off", "bypass=off", "speaker=a", "mute=off", "bass=000", "treble=000", "balance=000", "dimmer=0"} {
+		if _, err := this.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	this.resync()
+	if query := this.Update(false); query != "model?" {
+		t.Fatal("unexpected query", query)
+	}
+	this.Set("model=A14")
+	if query := this.Update(false); query != "power?" {
+		t.Fatal("unexpected query", query)
+	}
+	this.Set("power=standby")
+	if query := this.Update(false); query != "" {
+		t.Error("unexpected query", query)
+	}
+
+	// A resync in standby only queries the model and power
+	this.resync()
+	this.Set("power=on")
+	this.Set("power=standby")
+	if query := this.Update(false); query != "model?" {
+		t.Error("unexpected query", query)
+	}
+	this.Set("model=A14")
+	if query := this.Update(false); query != "power?" {
+		t.Error("unexpected query", query)
+	}
+	this.Set("power=standby")
+	if query := this.Update(false); query != "" {
+		t.Error("unexpected query", query)
+	}
+}
+
+func Test_State_003(t *testing.T) {
+	// Resync queries which are rejected or unanswered are skipped
+	self, _ := newRecorded(t, "model=A14", "power=standby")
+	self.state.resync()
+	if query := self.state.Update(false); query != "model?" {
+		t.Fatal("unexpected query", query)
+	}
+
+	// A rejected query is skipped
+	self.timeout("model?")
+	if err := self.parse(&self.state, []byte("unknown_command$")); !errors.Is(err, ErrDevice) {
+		t.Error("unexpected error:", err)
+	}
+	if query := self.state.Update(false); query != "power?" {
+		t.Fatal("unexpected query", query)
+	}
+
+	// An unanswered query is skipped after deltaResponse, even when other
+	// responses are arriving
+	self.timeout("power?")
+	self.unanswered(&self.state, false)
+	if query := self.state.Update(false); query != "power?" {
+		t.Fatal("unexpected query", query)
+	}
+	self.asked = time.Now().Add(-2 * deltaResponse)
+	self.received = time.Now()
+	self.unanswered(&self.state, false)
+	if query := self.state.Update(false); query != "" {
+		t.Error("unexpected query", query)
+	}
+}
+
+func Test_State_004(t *testing.T) {
+	// When the firmware ignores rs232_update_on!, updates are requested
+	// once per connection and the hybrid strategy polls for changes
+	conn := new(recorder)
+	self, err := NewWithConn(Config{Strategy: STRATEGY_HYBRID}, func() (io.ReadWriteCloser, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, param := range []string{"model=A14", "power=on", "volume=20", "source=cd", "update_mode=manual"} {
+		if _, err := self.state.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	if query := self.state.Update(false); query != "rs232_update_on!" {
+		t.Fatal("unexpected query", query)
+	}
+	if _, err := self.state.Set("update_mode=manual"); err != nil {
+		t.Fatal(err)
+	} else if query := self.state.Update(false); query != "freq?" {
+		t.Fatal("unexpected query", query)
+	}
+	for _, param := range []string{"freq=off", "bypass=off", "speaker=a", "mute=off", "bass=000", "treble=000", "balance=000", "dimmer=0"} {
+		if _, err := self.state.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+
+	// All values are known, and a poll is due
+	if query := self.state.Update(false); query != "" {
+		t.Fatal("unexpected query", query)
+	} else if query := self.poll(&self.state, query); query != "model?" {
+		t.Fatal("expected a poll, got", query)
+	}
+
+	// Updates are requested again when reconnected
+	if err := self.reconnect(&self.state); err != nil {
+		t.Fatal(err)
+	} else if query := self.state.Update(false); query != "rs232_update_on!" {
+		t.Error("unexpected query", query)
+	}
+}
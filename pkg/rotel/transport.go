@@ -0,0 +1,398 @@
+package rotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// transport is the RS232 connection and response framing shared by all
+// device types. The connection is reopened when it fails
+type transport struct {
+	*bus
+	*watchdog
+	*history
+	mu          sync.Mutex         // Guards fd
+	fd          io.ReadWriteCloser // TTY file handle, or nil when disconnected
+	open        func() (io.ReadWriteCloser, error)
+	readTimeout time.Duration // Read timeout
+	interval    time.Duration // Time between queries
+	retry       time.Duration // Time between attempts to reopen the connection
+	framer      *framer
+	poller      *poller
+	query       string    // Query waiting for a response
+	sent        time.Time // Time the query was sent, or the last response
+	asked       time.Time // Time the query was first sent
+}
+
+// device is implemented by the state of each device type, which generates
+// queries for unknown values and parses responses
+type device interface {
+	Update(force bool) string
+	Set(param string) (Flag, error)
+}
+
+// deadline is implemented by connections which do not time out reads
+// themselves, for example network connections and pipes
+type deadline interface {
+	SetReadDeadline(time.Time) error
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newTransport(cfg Config) (*transport, error) {
+	// Set tty from config
+	if cfg.TTY == "" {
+		cfg.TTY = DEFAULT_TTY
+	}
+	if cfg.Baud == 0 {
+		cfg.Baud = DEFAULT_TTY_BAUD
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DEFAULT_TTY_TIMEOUT
+	}
+
+	// Check parameters
+	if err := checkSerial(&cfg); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(cfg.TTY); os.IsNotExist(err) {
+		return nil, ErrBadParameter.With("tty: ", strconv.Quote(cfg.TTY))
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Open term with the line settings and read timeout
+	return newTransportWith(cfg, func() (io.ReadWriteCloser, error) {
+		return openSerial(cfg)
+	})
+}
+
+// newTransportWith returns a transport for the connection returned by
+// open, which is called again to reconnect when the connection fails
+func newTransportWith(cfg Config, open func() (io.ReadWriteCloser, error)) (*transport, error) {
+	self := new(transport)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DEFAULT_TTY_TIMEOUT
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DEFAULT_INTERVAL
+	}
+	if cfg.Reconnect == 0 {
+		cfg.Reconnect = DEFAULT_RECONNECT
+	}
+
+	// Open the connection
+	if fd, err := open(); err != nil {
+		return nil, err
+	} else {
+		self.fd = fd
+		self.open = open
+		self.readTimeout = cfg.Timeout
+		self.interval = cfg.Interval
+		self.retry = cfg.Reconnect
+		self.framer = newFramer(cfg.MaxFrame)
+		self.poller = newPoller(cfg)
+		self.bus = newBus()
+		self.watchdog = newWatchdog(cfg)
+		self.history = newHistory(cfg.History)
+	}
+
+	// Return success
+	return self, nil
+}
+
+// run queries the device for unknown state and parses responses until the
+// context is cancelled, then closes the connection. If ch is not nil, all
+// events are also sent on it
+func (self *transport) run(ctx context.Context, dev device, ch chan<- Event) error {
+	// Forward events to the channel
+	if ch != nil {
+		self.forward(ctx, ch)
+	}
+
+	// Start the watchdog
+	self.reset()
+
+	// Update status every 100ms
+	timer := time.NewTimer(100 * time.Millisecond)
+	defer timer.Stop()
+
+	// Loop handling messages until done
+FOR_LOOP:
+	for {
+		// Reopen the connection when disconnected
+		if self.conn() == nil {
+			select {
+			case <-ctx.Done():
+				break FOR_LOOP
+			case <-timer.C:
+				if err := self.reconnect(dev); err != nil {
+					self.publish(newError(fmt.Errorf("reconnect: %w", ErrDisconnected.With(err))))
+					timer.Reset(self.retry)
+				} else {
+					timer.Reset(self.interval)
+				}
+			}
+			continue FOR_LOOP
+		}
+
+		select {
+		case <-ctx.Done():
+			break FOR_LOOP
+		case <-timer.C:
+			self.unanswered(dev, false)
+			if cmd := self.poll(dev, self.watch(dev, dev.Update(false))); cmd != "" {
+				if err := self.timeout(cmd); err != nil {
+					self.publish(newError(err))
+				}
+				if err := self.writetty(cmd); err != nil {
+					self.disconnect(dev)
+					self.publish(newError(fmt.Errorf("writetty: %w", ErrDisconnected.With(err))))
+				}
+			} else {
+				self.query = ""
+			}
+			if self.conn() == nil {
+				timer.Reset(self.retry)
+			} else {
+				timer.Reset(self.interval)
+			}
+		default:
+			if err := self.readtty(dev); err != nil {
+				self.publish(newError(fmt.Errorf("readtty: %w", err)))
+				if self.conn() == nil {
+					timer.Reset(self.retry)
+				}
+			}
+		}
+	}
+
+	// Restore the device settings
+	var result error
+	if r, ok := dev.(restorer); ok {
+		if cmd := r.restore(); cmd != "" && self.conn() != nil {
+			if err := self.writetty(cmd); err != nil {
+				result = errors.Join(result, err)
+			}
+		}
+	}
+
+	// Close RS232 connection
+	self.mu.Lock()
+	if self.fd != nil {
+		if err := self.fd.Close(); err != nil {
+			result = errors.Join(result, err)
+		}
+	}
+
+	// Clear resources
+	self.fd = nil
+	self.mu.Unlock()
+
+	// Return any errors
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (self *transport) String() string {
+	str := "<transport"
+	if self.fd == nil {
+		str += " disconnected"
+	} else {
+		str += fmt.Sprintf(" tty=%q", self.fd)
+	}
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (self *transport) readtty(dev device) error {
+	fd := self.conn()
+
+	// Connections without a read timeout use a deadline
+	if fd, ok := fd.(deadline); ok {
+		if err := fd.SetReadDeadline(time.Now().Add(self.readTimeout)); err != nil {
+			self.disconnect(dev)
+			return ErrDisconnected.With(err)
+		}
+	}
+
+	// Read data, where a timeout returns no data. Serial ports return
+	// io.EOF when a read times out, but other connections return it when
+	// the other end has closed the connection
+	buf := make([]byte, 1024)
+	if n, err := fd.Read(buf); errors.Is(err, os.ErrDeadlineExceeded) || (err == io.EOF && isSerial(fd)) {
+		return nil
+	} else if err != nil {
+		self.disconnect(dev)
+		return ErrDisconnected.With(err)
+	} else {
+		return self.parse(dev, buf[:n])
+	}
+}
+
+// parse frames data into responses, sets state from any complete
+// responses and publishes an event for the changes
+func (self *transport) parse(dev device, data []byte) error {
+	var flags Flag
+
+	// Record the state before any change
+	old := stateOf(dev)
+
+	// Parse each response and update state
+	params, result := self.framer.write(dev, data)
+	for _, param := range params {
+		if err := responseError(param); err != nil {
+			if errors.Is(err, ErrDevice) {
+				self.unanswered(dev, true)
+			}
+			result = errors.Join(result, err)
+		} else if flag, err := dev.Set(param); err != nil {
+			result = errors.Join(result, fmt.Errorf("%q: %w", param, err))
+		} else {
+			flags |= flag | self.alive(dev)
+		}
+	}
+
+	// Poll again soon after a change
+	if flags&^ROTEL_FLAG_OFFLINE != ROTEL_FLAG_NONE {
+		self.poller.active()
+	}
+
+	// If any flags set, then emit an event
+	if flags != ROTEL_FLAG_NONE {
+		self.publish(Event{Flag: flags, Time: time.Now(), Old: old, New: stateOf(dev)})
+	}
+
+	// Return any errors
+	return result
+}
+
+// disconnect closes a failed connection, discards any partial response
+// and marks the device offline until the connection is reopened
+func (self *transport) disconnect(dev device) {
+	self.mu.Lock()
+	if self.fd != nil {
+		self.fd.Close()
+		self.fd = nil
+	}
+	self.mu.Unlock()
+	self.framer.reset()
+	self.query = ""
+	self.lost(dev, time.Now())
+}
+
+// reconnect reopens the connection, and re-queries all values in case
+// they changed while disconnected
+func (self *transport) reconnect(dev device) error {
+	fd, err := self.open()
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	self.fd = fd
+	self.mu.Unlock()
+	self.synced = time.Now()
+	if w, ok := dev.(watched); ok {
+		w.reconnected()
+		w.resync()
+	}
+	return nil
+}
+
+// timeout returns ErrTimeout when the same query has been sent repeatedly
+// and no response has been received for longer than deltaResponse
+func (self *transport) timeout(cmd string) error {
+	if cmd != self.query {
+		self.query = cmd
+		self.sent = time.Now()
+		self.asked = self.sent
+	} else if self.received.After(self.sent) {
+		// The device is responding, so wait again from the last response
+		self.sent = self.received
+	} else if time.Since(self.sent) > deltaResponse {
+		self.sent = time.Now()
+		return ErrTimeout.With(cmd)
+	}
+	return nil
+}
+
+// forward sends all events to a channel until the context is cancelled
+func (self *transport) forward(ctx context.Context, ch chan<- Event) {
+	go func(events <-chan Event) {
+		for evt := range events {
+			select {
+			case ch <- evt:
+			case <-ctx.Done():
+			}
+		}
+	}(self.Subscribe(ctx, ROTEL_FLAG_NONE))
+}
+
+// publish records an event in the history and sends it to subscribers
+func (self *transport) publish(evt Event) {
+	self.history.event(evt)
+	self.bus.publish(evt)
+}
+
+func (self *transport) writetty(cmd string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.fd == nil {
+		return ErrDisconnected.With(cmd)
+	}
+	self.history.command(cmd)
+	_, err := self.fd.Write([]byte(cmd))
+	return err
+}
+
+// send encodes and writes a command to the device, and polls again soon
+// for any changes
+func (self *transport) send(cmd protocol.Command) error {
+	if err := self.writetty(string(cmd.Encode())); err != nil {
+		return err
+	}
+	self.poller.active()
+	return nil
+}
+
+// conn returns the connection, or nil when disconnected
+func (self *transport) conn() io.ReadWriteCloser {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.fd
+}
+
+// stateOf returns a snapshot of the state of a device, or nil for other
+// device types
+func stateOf(dev device) Snapshot {
+	switch dev := dev.(type) {
+	case *state:
+		return dev.State()
+	case *cdstate:
+		return dev.State()
+	case *tunerstate:
+		return dev.State()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package rotel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Transport_001(t *testing.T) {
+	// A repeated query times out only when no response has been received
+	self, err := newTransportWith(Config{}, func() (io.ReadWriteCloser, error) {
+		return new(recorder), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.timeout("mode?"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Responses are still arriving
+	self.sent = time.Now().Add(-2 * deltaResponse)
+	self.received = time.Now()
+	if err := self.timeout("mode?"); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	// The device has stopped responding
+	self.sent = time.Now().Add(-2 * deltaResponse)
+	self.received = self.sent.Add(-time.Second)
+	if err := self.timeout("mode?"); !errors.Is(err, ErrTimeout) {
+		t.Error("unexpected error:", err)
+	}
+
+	// A different query restarts the wait
+	self.sent = time.Now().Add(-2 * deltaResponse)
+	if err := self.timeout("format?"); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func Test_Transport_002(t *testing.T) {
+	// Events from CD players and tuners carry snapshots of their state
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	self, ch := newSubscribed(t, ctx)
+	cd := new(cdstate)
+	if err := self.parse(cd, []byte("model=CD14$power=on$status=play$track=3$time=1:05$")); err != nil {
+		t.Fatal(err)
+	}
+	evt := <-ch
+	if old, ok := evt.Old.(CDState); !ok || old != (CDState{}) {
+		t.Errorf("unexpected old state: %#v", evt.Old)
+	}
+	if state, ok := evt.New.(CDState); !ok {
+		t.Errorf("unexpected new state: %#v", evt.New)
+	} else if state.Status != "play" || state.Track != 3 || state.Time != 65*time.Second {
+		t.Errorf("unexpected new state: %+v", state)
+	}
+
+	self, ch = newSubscribed(t, ctx)
+	tuner := new(tunerstate)
+	if err := self.parse(tuner, []byte("model=T14$power=on$band=fm$freq=98.50$rds=RADIO 4$")); err != nil {
+		t.Fatal(err)
+	}
+	evt = <-ch
+	if state, ok := evt.New.(TunerState); !ok {
+		t.Errorf("unexpected new state: %#v", evt.New)
+	} else if state.Band != "fm" || state.Frequency != 98.5 || state.Text != "RADIO 4" {
+		t.Errorf("unexpected new state: %+v", state)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// newSubscribed returns a transport which writes to a recorder, and a
+// channel of its events
+func newSubscribed(t *testing.T, ctx context.Context) (*transport, <-chan Event) {
+	t.Helper()
+	self, err := newTransportWith(Config{}, func() (io.ReadWriteCloser, error) {
+		return new(recorder), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return self, self.Subscribe(ctx, ROTEL_FLAG_NONE)
+}
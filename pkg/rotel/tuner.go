@@ -0,0 +1,167 @@
+package rotel
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Tuner is a Rotel FM/DAB tuner (RT series) which uses the same RS232
+// protocol as the amplifiers
+type Tuner struct {
+	tunerstate
+	*transport
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	PRESET_MIN = 1
+	PRESET_MAX = 30
+	FM_MIN     = 87.5   // MHz
+	FM_MAX     = 108.0  // MHz
+	FM_STEP    = 0.05   // MHz
+	AM_MIN     = 522.0  // kHz
+	AM_MAX     = 1710.0 // kHz
+	AM_STEP    = 9.0    // kHz
+)
+
+var (
+	BANDS = []string{
+		"fm", "am", "dab",
+	}
+)
+
+var (
+	reChannel = regexp.MustCompile("^\\d{1,2}[A-Z]$")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewTunerWithConfig(cfg Config) (*Tuner, error) {
+	self := new(Tuner)
+
+	// Open the transport
+	if transport, err := newTransport(cfg); err != nil {
+		return nil, err
+	} else {
+		self.transport = transport
+	}
+
+	// Return success
+	return self, nil
+}
+
+func (self *Tuner) Run(ctx context.Context, ch chan<- Event) error {
+	return self.transport.run(ctx, &self.tunerstate, ch)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (self *Tuner) SetPower(state bool) error {
+	return self.send(protocol.SetPower(state))
+}
+
+func (self *Tuner) SetBand(value string) error {
+	// Cannot set value when power is off
+	if !self.Power() {
+		return ErrPowerOff.With("SetBand")
+	}
+
+	// Check parameter and send command
+	for _, band := range BANDS {
+		if band == value {
+			return self.send(protocol.SetBand(value))
+		}
+	}
+	return ErrBadParameter.Withf("invalid band: %q", value)
+}
+
+// SetFrequency tunes to a frequency in MHz for FM or kHz for AM
+func (self *Tuner) SetFrequency(value float64) error {
+	// Cannot set value when power is off
+	if !self.Power() {
+		return ErrPowerOff.With("SetFrequency")
+	}
+
+	// Check parameter and send command
+	switch self.Band() {
+	case "fm":
+		if value < FM_MIN || value > FM_MAX {
+			return ErrOutOfRange.Withf("frequency: %v", value)
+		}
+		return self.send(protocol.SetFrequency{Band: "fm", Value: value})
+	case "am":
+		if value < AM_MIN || value > AM_MAX {
+			return ErrOutOfRange.Withf("frequency: %v", value)
+		}
+		return self.send(protocol.SetFrequency{Band: "am", Value: value})
+	default:
+		return ErrUnsupported.Withf("SetFrequency: band %q", self.Band())
+	}
+}
+
+// SetChannel tunes to a DAB channel, for example 12B
+func (self *Tuner) SetChannel(value string) error {
+	// Cannot set value when power is off or not on DAB
+	if !self.Power() {
+		return ErrPowerOff.With("SetChannel")
+	} else if band := self.Band(); band != "dab" {
+		return ErrUnsupported.Withf("SetChannel: band %q", band)
+	}
+
+	// Check parameter and send command
+	if !reChannel.MatchString(value) {
+		return ErrBadParameter.Withf("invalid channel: %q", value)
+	}
+	return self.send(protocol.SetChannel(value))
+}
+
+// RecallPreset tunes to a stored preset
+func (self *Tuner) RecallPreset(value uint) error {
+	return self.sendPreset("RecallPreset", value, protocol.RecallPreset(value))
+}
+
+// StorePreset stores the current station in a preset
+func (self *Tuner) StorePreset(value uint) error {
+	return self.sendPreset("StorePreset", value, protocol.StorePreset(value))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (self *Tuner) String() string {
+	str := "<tuner"
+	if self.transport != nil && self.fd != nil {
+		str += fmt.Sprintf(" tty=%q", self.fd)
+	}
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (self *Tuner) sendPreset(name string, value uint, cmd protocol.Setter) error {
+	// Cannot set value when power is off
+	if !self.Power() {
+		return ErrPowerOff.With(name)
+	}
+
+	// Check parameter and send command
+	if !cmd.Valid() {
+		return ErrOutOfRange.Withf("preset: %d", value)
+	}
+	return self.send(cmd)
+}
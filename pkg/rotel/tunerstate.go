@@ -0,0 +1,186 @@
+package rotel
+
+import (
+	"strconv"
+	"sync"
+
+	// Packages
+	protocol "github.com/djthorpe/go-rotel/pkg/rotel/protocol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// TunerState is a snapshot of the tuner state
+type TunerState struct {
+	Model     string  `json:"model"`
+	Power     bool    `json:"power"`
+	Band      string  `json:"band,omitempty"`      // fm, am or dab
+	Frequency float64 `json:"frequency,omitempty"` // MHz for FM, kHz for AM
+	Channel   string  `json:"channel,omitempty"`   // DAB channel
+	Preset    uint    `json:"preset,omitempty"`
+	Text      string  `json:"text,omitempty"` // RDS text for FM, DLS text for DAB
+}
+
+type tunerstate struct {
+	mu      sync.RWMutex
+	model   string
+	power   string
+	update  string // rs232 update
+	band    string
+	freq    string
+	channel string
+	preset  string
+	rds     *string // Station text, or nil when not yet read
+	dls     *string // Station text, or nil when not yet read
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PROPERTIES
+
+func (this *tunerstate) Model() string {
+	return this.State().Model
+}
+
+func (this *tunerstate) Power() bool {
+	return this.State().Power
+}
+
+// Band returns fm, am or dab
+func (this *tunerstate) Band() string {
+	return this.State().Band
+}
+
+// Frequency returns the tuned frequency in MHz for FM and kHz for AM
+func (this *tunerstate) Frequency() float64 {
+	return this.State().Frequency
+}
+
+// Channel returns the tuned DAB channel
+func (this *tunerstate) Channel() string {
+	return this.State().Channel
+}
+
+// Preset returns the current preset, or zero if not tuned to a preset
+func (this *tunerstate) Preset() uint {
+	return this.State().Preset
+}
+
+// Text returns the RDS text for FM, or the DLS text for DAB
+func (this *tunerstate) Text() string {
+	return this.State().Text
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// State returns a consistent snapshot of the tuner state. Values other
+// than the model and power are empty when the power is off
+func (this *tunerstate) State() TunerState {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	s := TunerState{Model: this.model, Power: this.power == "on"}
+	if !s.Power {
+		return s
+	}
+	s.Band = this.band
+	if preset, err := strconv.ParseUint(this.preset, 0, 32); err == nil {
+		s.Preset = uint(preset)
+	}
+	if this.band == "dab" {
+		s.Channel = this.channel
+		if this.dls != nil {
+			s.Text = *this.dls
+		}
+	} else {
+		if freq, err := strconv.ParseFloat(this.freq, 64); err == nil {
+			s.Frequency = freq
+		}
+		if this.rds != nil {
+			s.Text = *this.rds
+		}
+	}
+	return s
+}
+
+// Update returns a query to get state of an unknown value
+func (this *tunerstate) Update(force bool) string {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	switch {
+	case this.model == "":
+		return "model?"
+	case this.power == "" || force:
+		return "power?"
+	case this.power != "on": // When power is off, don't read other values
+		return ""
+	case this.update == "":
+		return "rs232_update_on!"
+	case this.band == "":
+		return "band?"
+	case this.band == "dab" && this.channel == "":
+		return "channel?"
+	case this.band != "dab" && this.freq == "":
+		return "freq?"
+	case this.preset == "":
+		return "preset?"
+	case this.band == "dab" && this.dls == nil:
+		return "dls?"
+	case this.band == "fm" && this.rds == nil:
+		return "rds?"
+	}
+
+	// By default, no state needs read
+	return ""
+}
+
+// Set sets state from data coming from the tuner
+func (this *tunerstate) Set(param string) (Flag, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// Decode the response
+	response, err := protocol.TUNER.Decode(param)
+	if err != nil {
+		return 0, err
+	}
+
+	// Set the state
+	return this.set(response)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (this *tunerstate) setBand(band protocol.Band) (Flag, error) {
+	if string(band) == this.band {
+		return 0, nil
+	}
+	this.band = string(band)
+
+	// Tuning and station text are read again for the new band
+	this.freq, this.channel, this.rds, this.dls = "", "", nil, nil
+
+	// Return the band changed flag
+	return ROTEL_FLAG_BAND, nil
+}
+
+func (this *tunerstate) setRDS(text protocol.RDS) (Flag, error) {
+	if this.rds == nil || string(text) != *this.rds {
+		value := string(text)
+		this.rds = &value
+		return ROTEL_FLAG_TEXT, nil
+	}
+	return 0, nil
+}
+
+func (this *tunerstate) setDLS(text protocol.DLS) (Flag, error) {
+	if this.dls == nil || string(text) != *this.dls {
+		value := string(text)
+		this.dls = &value
+		return ROTEL_FLAG_TEXT, nil
+	}
+	return 0, nil
+}
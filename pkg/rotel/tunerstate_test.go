@@ -0,0 +1,62 @@
+package rotel
+
+import (
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Tuner_001(t *testing.T) {
+	// Empty station text is known, and is not queried again
+	this := new(tunerstate)
+	for _, param := range []string{"model=T14", "power=on", "update_mode=auto", "band=fm", "freq=98.5", "preset=3"} {
+		if _, err := this.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	if query := this.Update(false); query != "rds?" {
+		t.Errorf("expected rds?, got %q", query)
+	}
+	if flag, err := this.Set("rds="); err != nil {
+		t.Fatal(err)
+	} else if flag != ROTEL_FLAG_TEXT {
+		t.Error("unexpected flags", flag)
+	}
+	if query := this.Update(false); query != "" {
+		t.Errorf("unexpected query %q", query)
+	}
+	if flag, _ := this.Set("rds="); flag != ROTEL_FLAG_NONE {
+		t.Error("unexpected flags", flag)
+	}
+}
+
+func Test_Tuner_002(t *testing.T) {
+	// Station text is read again when the band changes
+	this := new(tunerstate)
+	for _, param := range []string{"model=T14", "power=on", "update_mode=auto", "band=fm", "freq=98.5", "preset=3", "rds=RADIO 4"} {
+		if _, err := this.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	if text := this.Text(); text != "RADIO 4" {
+		t.Errorf("unexpected text %q", text)
+	}
+	for _, param := range []string{"band=dab", "channel=12B"} {
+		if _, err := this.Set(param); err != nil {
+			t.Fatal(param, err)
+		}
+	}
+	if text := this.Text(); text != "" {
+		t.Errorf("unexpected text %q", text)
+	}
+	if query := this.Update(false); query != "dls?" {
+		t.Errorf("expected dls?, got %q", query)
+	}
+	if _, err := this.Set("dls=001,"); err != nil {
+		t.Fatal(err)
+	}
+	if query := this.Update(false); query != "" {
+		t.Errorf("unexpected query %q", query)
+	}
+}
@@ -0,0 +1,175 @@
+package rotel
+
+import (
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// watchdog tracks the time since the last valid response from a device
+type watchdog struct {
+	keepalive time.Duration // Idle time before sending a keepalive query
+	silence   time.Duration // Silence before the device is marked offline
+	resync    time.Duration // Interval between re-querying all values
+
+	received time.Time // Last valid response
+	polled   time.Time // Last keepalive query
+	synced   time.Time // Last resync
+	offline  bool
+}
+
+// watched is implemented by the state of devices which are marked stale
+// when offline, and which can re-query all values
+type watched interface {
+	setOffline(bool) Flag
+	resync()
+	reconnected()
+	skip(query string)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newWatchdog(cfg Config) *watchdog {
+	self := new(watchdog)
+	if self.keepalive = cfg.Keepalive; self.keepalive == 0 {
+		self.keepalive = DEFAULT_KEEPALIVE
+	}
+	if self.silence = cfg.Silence; self.silence == 0 {
+		self.silence = DEFAULT_SILENCE
+	}
+	if self.resync = cfg.Resync; self.resync == 0 {
+		self.resync = DEFAULT_RESYNC
+	}
+	return self
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// reset starts the watchdog
+func (self *watchdog) reset() {
+	now := time.Now()
+	self.received, self.polled, self.synced = now, now, now
+	self.offline = false
+}
+
+// watch is called periodically with the next query for the device, and
+// returns the query to send. It sends a keepalive query when idle, marks
+// the device offline after a silence and starts a resync when due
+func (self *transport) watch(dev device, cmd string) string {
+	now := time.Now()
+
+	// Mark offline after a silence
+	if now.Sub(self.received) > self.silence {
+		self.lost(dev, now)
+	}
+
+	// Start a resync when due
+	if now.Sub(self.synced) > self.resync {
+		self.synced = now
+		if w, ok := dev.(watched); ok {
+			w.resync()
+			if cmd == "" {
+				cmd = dev.Update(false)
+			}
+		}
+	}
+
+	// Send a keepalive query when idle
+	if cmd == "" && now.Sub(self.received) > self.keepalive && now.Sub(self.polled) > self.keepalive {
+		self.polled = now
+		cmd = dev.Update(true)
+	}
+
+	// Return the query
+	return cmd
+}
+
+// unanswered skips a resync query which the device rejected, or which
+// has not been answered for deltaResponse, so that the resync continues
+func (self *transport) unanswered(dev device, rejected bool) {
+	if self.query == "" || (!rejected && time.Since(self.asked) <= deltaResponse) {
+		return
+	}
+	if w, ok := dev.(watched); ok {
+		w.skip(self.query)
+	}
+}
+
+// lost marks the device offline, and publishes an event if changed
+func (self *transport) lost(dev device, now time.Time) {
+	if self.offline {
+		return
+	}
+	self.offline = true
+	old := stateOf(dev)
+	if w, ok := dev.(watched); ok {
+		self.publish(Event{Flag: w.setOffline(true), Time: now, Old: old, New: stateOf(dev)})
+	} else {
+		self.publish(Event{Flag: ROTEL_FLAG_OFFLINE, Time: now, Old: old, New: stateOf(dev)})
+	}
+}
+
+// alive is called when a valid response is received, and returns
+// ROTEL_FLAG_OFFLINE if the device was offline
+func (self *transport) alive(dev device) Flag {
+	self.received = time.Now()
+	if !self.offline {
+		return ROTEL_FLAG_NONE
+	}
+	self.offline = false
+	if w, ok := dev.(watched); ok {
+		return w.setOffline(false)
+	}
+	return ROTEL_FLAG_OFFLINE
+}
+
+// setOffline marks the state as stale when the device stops responding,
+// and returns ROTEL_FLAG_OFFLINE if changed
+func (this *state) setOffline(value bool) Flag {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.offline == value {
+		return ROTEL_FLAG_NONE
+	}
+	this.offline = value
+	return ROTEL_FLAG_OFFLINE
+}
+
+// resync queues queries for every known value
+func (this *state) resync() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.requery = []string{"model?", "power?"}
+	if this.power != "on" {
+		return
+	}
+	this.requery = append(this.requery, "volume?", "source?", "freq?", "bypass?", "speaker?", "mute?", "bass?", "treble?", "balance?", "dimmer?")
+	profile := ProfileForModel(this.model)
+	if profile.Phono {
+		this.requery = append(this.requery, "phono_mode?")
+	}
+	if profile.Processor {
+		this.requery = append(this.requery, "mode?", "format?", "center?", "subwoofer?", "surround?")
+	}
+}
+
+// reconnected is called when the connection is reopened, so that updates
+// are requested again
+func (this *state) reconnected() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.requested = false
+}
+
+// skip removes a query from the head of the resync queries
+func (this *state) skip(query string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if len(this.requery) > 0 && this.requery[0] == query {
+		this.requery = this.requery[1:]
+	}
+}